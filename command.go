@@ -0,0 +1,322 @@
+package conf
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// Cmd is a node of a command tree, it carries the configuration of a command,
+// its sub-commands and the function that runs it.
+//
+// The configuration of a command is inherited by all its sub-commands, which
+// means that options declared by the root of the tree are global options that
+// can be set at every level of the command line, for example:
+//
+//	prog -verbose db migrate -steps 2
+type Cmd struct {
+	Name     string                                         // name of the command
+	Help     string                                         // help message describing what the command does
+	Config   interface{}                                    // pointer to the configuration struct of the command, may be nil
+	Commands []Cmd                                          // list of sub-commands
+	Run      func(ctx context.Context, args []string) error // runs the command with the leftover arguments
+}
+
+// Run loads the configuration of the command tree rooted at cmd from the
+// program arguments, environment and configuration file, then runs the
+// command selected by the arguments.
+//
+// If an error is detected while loading the configuration the function prints
+// the usage message to stderr and exits with status code 1, otherwise it
+// returns the error returned by the command.
+func Run(ctx context.Context, cmd Cmd) error {
+	return RunWith(ctx, cmd, DefaultLoader)
+}
+
+// RunWith behaves like Run but uses ld as a loader to parse the program
+// configuration. The name of the root command is ignored, ld.Name is used
+// instead.
+func RunWith(ctx context.Context, cmd Cmd, ld Loader) error {
+	if ld.OnDeprecated == nil {
+		ld.OnDeprecated = printDeprecation
+	}
+
+	path, node, args, err := ld.loadCommand(cmd)
+
+	switch err {
+	case nil:
+	case flag.ErrHelp:
+		ld.PrintCommandHelp(path...)
+		os.Exit(0)
+	default:
+		switch e := err.(type) {
+		case *PrintConfigError:
+			if err = ld.printConfig(node, e); err == nil {
+				os.Exit(0)
+			}
+		case *CompletionError:
+			if err = ld.printCommandCompletion(cmd, e.Shell); err == nil {
+				os.Exit(0)
+			}
+		}
+		ld.PrintCommandHelp(path...)
+		ld.PrintError(err)
+		os.Exit(1)
+	}
+
+	return runCommand(ctx, path, args)
+}
+
+// Run uses the loader ld to load the configuration of the command tree rooted
+// at cmd, then runs the command selected by the loader's arguments.
+//
+// The configuration of each command on the path from cmd to the selected
+// command is loaded, modified and validated before the selected command runs.
+// Errors are returned the same way than Load does, or are the errors returned
+// by the command.
+func (ld Loader) Run(ctx context.Context, cmd Cmd) error {
+	path, _, args, err := ld.loadCommand(cmd)
+	if err != nil {
+		return err
+	}
+	return runCommand(ctx, path, args)
+}
+
+func runCommand(ctx context.Context, path []Cmd, args []string) error {
+	cmd := path[len(path)-1]
+	if cmd.Run == nil {
+		return fmt.Errorf("command cannot be run: %s", cmd.Name)
+	}
+	return cmd.Run(ctx, args)
+}
+
+// loadCommand selects the command to run in the tree rooted at root, and loads
+// the configuration of all commands on the path to it.
+//
+// The returned path is always valid, even when an error is returned, so the
+// help message of the last command that was found can be printed.
+func (ld Loader) loadCommand(root Cmd) (path []Cmd, node Map, args []string, err error) {
+	var flags []string
+	var pc *printConfigFlags
+	var origins map[string]Origin
+
+	path = []Cmd{root}
+	args = ld.Args
+
+	// The first argument of a root command which runs may be a positional
+	// argument rather than the completion command.
+	names := make([]string, len(root.Commands))
+	for i, c := range root.Commands {
+		names[i] = c.Name
+	}
+
+	if err = ld.completionRequest(root.Run == nil && completionCommand(names)); err != nil {
+		return
+	}
+
+	// Walk down the command tree, each level parses the options it knows about
+	// (including those inherited from the parent commands) until it finds the
+	// name of a sub-command.
+	for {
+		cmd := path[len(path)-1]
+		set := newFlagSet(makeCommandNode(path), ld.Name, ld.Sources...)
+
+		if ld.PrintConfig {
+			(&printConfigFlags{}).register(set)
+		}
+
+		if err = parseFlags(set, args, ld.GNU); err != nil {
+			return
+		}
+
+		rest := set.Args()
+		used := args[:len(args)-len(rest)]
+		args = rest
+
+		// Sub-commands cannot be selected after the "--" terminator.
+		terminated := false
+		if n := len(used); n != 0 && used[n-1] == "--" {
+			used, terminated = used[:n-1], true
+		}
+
+		flags = append(flags, used...)
+
+		if len(cmd.Commands) == 0 {
+			break
+		}
+
+		if terminated || len(args) == 0 {
+			if cmd.Run != nil {
+				break
+			}
+			err = ErrMissingCommand
+			return
+		}
+
+		sub, ok := findCommand(cmd.Commands, args[0])
+		if !ok {
+			if cmd.Run != nil {
+				break
+			}
+			names := make([]string, len(cmd.Commands))
+			for i, c := range cmd.Commands {
+				names[i] = c.Name
+			}
+			err = &UnknownCommandError{Name: args[0], Suggestion: suggest(args[0], names)}
+			return
+		}
+
+		path, args = append(path, sub), args[1:]
+	}
+
+	if ld.PrintConfig {
+		pc = &printConfigFlags{}
+		origins = make(map[string]Origin)
+	}
+
+	// Load the configuration of all commands at once, with the options found at
+	// every level followed by the leftover arguments.
+	ld.Args = append(append(flags, "--"), args...)
+	node = makeCommandNode(path)
+
+	var errs errorList
+
+	if args, errs, err = ld.load(node, origins, pc); err != nil {
+		return
+	}
+
+	for _, cmd := range path {
+		if cmd.Config != nil {
+			errs = errs.append(validateConfig(configValue(cmd.Config)))
+		}
+	}
+
+	if err = errs.err(); err != nil {
+		return
+	}
+
+	err = pc.request(origins)
+	return
+}
+
+func findCommand(cmds []Cmd, name string) (Cmd, bool) {
+	for _, cmd := range cmds {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Cmd{}, false
+}
+
+// makeCommandNode builds a node which holds the configuration fields of all the
+// commands in path.
+func makeCommandNode(path []Cmd) (m Map) {
+	m.value = reflect.ValueOf(&struct{}{}).Elem()
+	m.items = newMapItems()
+
+	names := make(map[string]string)
+
+	for _, cmd := range path {
+		if cmd.Config == nil {
+			continue
+		}
+
+		v := configValue(cmd.Config)
+
+		for _, item := range makeNodeStruct(v, v.Type()).Items() {
+			if prev, ok := names[item.Name]; ok {
+				panic("duplicate name '" + item.Name + "' found in the configurations of commands '" + prev + "' and '" + cmd.Name + "'")
+			}
+			names[item.Name] = cmd.Name
+			m.items.push(item)
+		}
+	}
+
+	return
+}
+
+// commandUsage returns the usage line of the last command in path.
+func (ld Loader) commandUsage(path []Cmd) string {
+	cmd := path[len(path)-1]
+	name := ld.Name
+
+	for _, c := range path[1:] {
+		name += " " + c.Name
+	}
+
+	switch {
+	case len(path) == 1 && len(ld.Usage) != 0:
+		return name + " " + ld.Usage
+	case len(cmd.Commands) != 0:
+		return name + " [command] [options...]"
+	default:
+		return name + " [-h] [" + flagName("help", ld.GNU) + "] [options...]"
+	}
+}
+
+// PrintCommandHelp outputs the help message of the last command in path to
+// stderr. The path is the list of commands leading from the root of a command
+// tree to the command.
+func (ld Loader) PrintCommandHelp(path ...Cmd) {
+	w := bufio.NewWriter(os.Stderr)
+	ld.fprintCommandHelp(w, path, stderr())
+	w.Flush()
+}
+
+// FprintCommandHelp outputs the help message of the last command in path to w.
+func (ld Loader) FprintCommandHelp(w io.Writer, path ...Cmd) {
+	ld.fprintCommandHelp(w, path, monochrome())
+}
+
+func (ld Loader) fprintCommandHelp(w io.Writer, path []Cmd, col colors) {
+	if len(path) == 0 {
+		ld.fprintHelp(w, nil, col)
+		return
+	}
+
+	cmd := path[len(path)-1]
+
+	fmt.Fprintf(w, "%s\n", col.titles("Usage:"))
+	fmt.Fprintf(w, "  %s\n\n", ld.commandUsage(path))
+
+	if len(path) > 1 && len(cmd.Help) != 0 {
+		fmt.Fprintf(w, "%s\n\n", cmd.Help)
+	}
+
+	if len(cmd.Commands) != 0 {
+		type line struct{ name, help string }
+		var lines []line
+		var walk func([]Cmd, string)
+
+		walk = func(cmds []Cmd, indent string) {
+			for _, c := range cmds {
+				lines = append(lines, line{indent + col.cmds(c.Name), c.Help})
+				walk(c.Commands, indent+"  ")
+			}
+		}
+
+		walk(cmd.Commands, "")
+		width := 0
+
+		for _, l := range lines {
+			if n := len(l.name); n > width {
+				width = n
+			}
+		}
+
+		fmt.Fprintf(w, "%s\n", col.titles("Commands:"))
+		cmdfmt := fmt.Sprintf("  %%-%ds  %%s\n", width)
+
+		for _, l := range lines {
+			fmt.Fprintf(w, cmdfmt, l.name, l.help)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	ld.fprintOptions(w, makeCommandNode(path), col)
+}
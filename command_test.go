@@ -0,0 +1,185 @@
+package conf
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+type commandTreeTest struct {
+	global struct {
+		Verbose bool `conf:"verbose" help:"Enable verbose output"`
+	}
+	db struct {
+		Host string `conf:"host" help:"Database host"`
+	}
+	migrate struct {
+		Steps int `conf:"steps" validate:"min=1"`
+	}
+	ran  string
+	args []string
+}
+
+func (test *commandTreeTest) tree() Cmd {
+	run := func(name string) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			test.ran, test.args = name, args
+			return nil
+		}
+	}
+	return Cmd{
+		Config: &test.global,
+		Commands: []Cmd{
+			{
+				Name:   "db",
+				Help:   "Manage the database",
+				Config: &test.db,
+				Commands: []Cmd{
+					{Name: "migrate", Help: "Run the migrations", Config: &test.migrate, Run: run("migrate")},
+					{Name: "seed", Help: "Load test data", Run: run("seed")},
+				},
+			},
+			{Name: "version", Help: "Print the version", Run: run("version")},
+		},
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	t.Run("Nested", func(t *testing.T) {
+		test := &commandTreeTest{}
+		ld := Loader{
+			Name:    "test",
+			Args:    []string{"-verbose", "db", "-host", "localhost", "migrate", "-steps", "2", "A", "B"},
+			Sources: []Source{NewEnvSource("test", "TEST_HOST=remote", "TEST_STEPS=3")},
+		}
+
+		if err := ld.Run(context.Background(), test.tree()); err != nil {
+			t.Fatal(err)
+		}
+
+		if test.ran != "migrate" || !reflect.DeepEqual(test.args, []string{"A", "B"}) {
+			t.Errorf("bad command: %s %v", test.ran, test.args)
+		}
+		if !test.global.Verbose || test.db.Host != "localhost" || test.migrate.Steps != 2 {
+			t.Errorf("bad configuration: %+v %+v %+v", test.global, test.db, test.migrate)
+		}
+	})
+
+	t.Run("InheritedOptions", func(t *testing.T) {
+		test := &commandTreeTest{}
+		ld := Loader{
+			Name: "test",
+			Args: []string{"db", "migrate", "-steps", "1", "-verbose", "-host", "localhost", "--", "-A"},
+		}
+
+		if err := ld.Run(context.Background(), test.tree()); err != nil {
+			t.Fatal(err)
+		}
+
+		if test.ran != "migrate" || !reflect.DeepEqual(test.args, []string{"-A"}) {
+			t.Errorf("bad command: %s %v", test.ran, test.args)
+		}
+		if !test.global.Verbose || test.db.Host != "localhost" || test.migrate.Steps != 1 {
+			t.Errorf("bad configuration: %+v %+v %+v", test.global, test.db, test.migrate)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		test := &commandTreeTest{}
+		ld := Loader{
+			Name: "test",
+			Args: []string{"db", "migrate"},
+		}
+
+		if err := ld.Run(context.Background(), test.tree()); err == nil || err.Error() != "invalid value passed to steps: less than min" {
+			t.Error("bad error:", err)
+		}
+		if test.ran != "" {
+			t.Error("command should not have run:", test.ran)
+		}
+	})
+
+	t.Run("MissingCommand", func(t *testing.T) {
+		test := &commandTreeTest{}
+		ld := Loader{
+			Name: "test",
+			Args: []string{"-verbose", "db"},
+		}
+
+		if err := ld.Run(context.Background(), test.tree()); err == nil || err.Error() != "missing command" {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("UnknownCommand", func(t *testing.T) {
+		test := &commandTreeTest{}
+		ld := Loader{
+			Name: "test",
+			Args: []string{"db", "drop"},
+		}
+
+		if err := ld.Run(context.Background(), test.tree()); err == nil || err.Error() != "unknown command: drop" {
+			t.Error("bad error:", err)
+		}
+
+		ld.Args = []string{"db", "migarte"}
+
+		if err := ld.Run(context.Background(), test.tree()); err == nil || err.Error() != "unknown command: migarte, did you mean migrate?" {
+			t.Error("bad error:", err)
+		}
+	})
+}
+
+func TestPrintCommandHelp(t *testing.T) {
+	test := &commandTreeTest{}
+	root := test.tree()
+	ld := Loader{Name: "test"}
+
+	t.Run("Root", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		ld.FprintCommandHelp(b, root)
+
+		const txt = "Usage:\n" +
+			"  test [command] [options...]\n" +
+			"\n" +
+			"Commands:\n" +
+			"  db         Manage the database\n" +
+			"    migrate  Run the migrations\n" +
+			"    seed     Load test data\n" +
+			"  version    Print the version\n" +
+			"\n" +
+			"Options:\n" +
+			"  -verbose\n" +
+			"    \tEnable verbose output\n" +
+			"\n"
+
+		if s := b.String(); s != txt {
+			t.Error(s)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		ld.FprintCommandHelp(b, root, root.Commands[0], root.Commands[0].Commands[0])
+
+		const txt = "Usage:\n" +
+			"  test db migrate [-h] [-help] [options...]\n" +
+			"\n" +
+			"Run the migrations\n" +
+			"\n" +
+			"Options:\n" +
+			"  -host string\n" +
+			"    \tDatabase host\n" +
+			"\n" +
+			"  -steps int\n" +
+			"\n" +
+			"  -verbose\n" +
+			"    \tEnable verbose output\n" +
+			"\n"
+
+		if s := b.String(); s != txt {
+			t.Error(s)
+		}
+	})
+}
@@ -0,0 +1,428 @@
+package conf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CompletionError is returned by Loader.Load and Loader.Run when the program
+// was called with the -completion flag on a loader with Completion set.
+//
+// Programs would usually print the completion script with FprintCompletion (or
+// FprintCommandCompletion) and exit, which is what LoadWith and RunWith do.
+type CompletionError struct {
+	Shell string // "bash", "zsh" or "fish"
+}
+
+// Error satisfies the error interface.
+func (e *CompletionError) Error() string {
+	return "generation of a " + e.Shell + " completion script was requested"
+}
+
+// UnsupportedShellError is returned when a completion script is requested for a
+// shell which is not supported.
+type UnsupportedShellError struct {
+	Shell string // name of the shell
+}
+
+// Error satisfies the error interface.
+func (e *UnsupportedShellError) Error() string {
+	return "unsupported shell: " + e.Shell
+}
+
+// completionRequest returns a *CompletionError if the loader supports
+// completion and the first program argument is the -completion flag.
+//
+// The flag is hidden and is only recognized as the first argument, which is how
+// it's used to install the scripts, for example:
+//
+//	source <(prog -completion bash)
+//
+// The completion command, without dashes, is only recognized when command is
+// true, see completionCommand.
+func (ld Loader) completionRequest(command bool) error {
+	if !ld.Completion || len(ld.Args) == 0 {
+		return nil
+	}
+
+	var shell string
+
+	switch arg := ld.Args[0]; {
+	case arg == "-completion" || arg == "--completion" || (command && arg == "completion"):
+		if len(ld.Args) < 2 {
+			return errors.New("flag needs an argument: -completion")
+		}
+		shell = ld.Args[1]
+	case strings.HasPrefix(arg, "-completion="):
+		shell = arg[12:]
+	case strings.HasPrefix(arg, "--completion="):
+		shell = arg[13:]
+	default:
+		return nil
+	}
+
+	switch shell {
+	case "bash", "zsh", "fish":
+		return &CompletionError{Shell: shell}
+	default:
+		return &UnsupportedShellError{Shell: shell}
+	}
+}
+
+// completionCommand returns true if the first argument of a program with the
+// given commands may be the completion command: the argument must be the name
+// of a command, and none of them is named completion.
+func completionCommand(commands []string) bool {
+	for _, c := range commands {
+		if c == "completion" {
+			return false
+		}
+	}
+	return len(commands) != 0
+}
+
+// FprintCompletion writes to w the script that the given shell ("bash", "zsh"
+// or "fish") uses to complete the commands and options of a program loading
+// its configuration into cfg with ld.
+func (ld Loader) FprintCompletion(w io.Writer, cfg interface{}, shell string) error {
+	flags := ld.completionFlags(makeConfigNode(cfg))
+	nodes := []completionNode{{path: ld.Name, flags: flags}}
+
+	for _, c := range ld.Commands {
+		nodes[0].commands = append(nodes[0].commands, completionItem{c.Name, c.Help})
+		nodes = append(nodes, completionNode{path: ld.Name + "/" + c.Name, flags: flags})
+	}
+
+	return fprintCompletion(w, ld.Name, nodes, shell)
+}
+
+// FprintCommandCompletion writes to w the script that the given shell ("bash",
+// "zsh" or "fish") uses to complete the commands and options of the command
+// tree rooted at root.
+func (ld Loader) FprintCommandCompletion(w io.Writer, root Cmd, shell string) error {
+	var nodes []completionNode
+	var walk func([]Cmd, string)
+
+	walk = func(path []Cmd, name string) {
+		cmd := path[len(path)-1]
+		node := completionNode{path: name, flags: ld.completionFlags(makeCommandNode(path))}
+
+		for _, c := range cmd.Commands {
+			node.commands = append(node.commands, completionItem{c.Name, c.Help})
+		}
+
+		nodes = append(nodes, node)
+
+		for _, c := range cmd.Commands {
+			walk(append(path[:len(path):len(path)], c), name+"/"+c.Name)
+		}
+	}
+
+	walk([]Cmd{root}, ld.Name)
+	return fprintCompletion(w, ld.Name, nodes, shell)
+}
+
+func (ld Loader) printCompletion(cfg interface{}, shell string) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := ld.FprintCompletion(w, cfg, shell); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (ld Loader) printCommandCompletion(root Cmd, shell string) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := ld.FprintCommandCompletion(w, root, shell); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// completionNode represents a command of the program, its path is the list of
+// command names leading to it, separated by slashes and starting with the
+// program name.
+type completionNode struct {
+	path     string
+	commands []completionItem
+	flags    []completionFlag
+}
+
+type completionItem struct {
+	name string
+	help string
+}
+
+// completionFlag is a flag of a command, its name has the leading dashes.
+type completionFlag struct {
+	completionItem
+	boolean bool // the flag takes no value
+	file    bool // the value of the flag is a file path
+}
+
+func (ld Loader) completionFlags(m Map) (flags []completionFlag) {
+	for _, o := range ld.options(m) {
+		flag := completionFlag{
+			completionItem: completionItem{flagName(o.name, ld.GNU), o.help},
+			boolean:        o.boolean,
+			file:           o.typ == "source",
+		}
+		flags = append(flags, flag)
+
+		if len(o.short) != 0 {
+			flag.name = "-" + o.short
+			flags = append(flags, flag)
+		}
+	}
+	return
+}
+
+func fprintCompletion(w io.Writer, name string, nodes []completionNode, shell string) error {
+	b := &strings.Builder{}
+	fn := completionFuncName(name)
+
+	switch shell {
+	case "bash":
+		writeBashCompletion(b, name, fn, nodes)
+	case "zsh":
+		writeZshCompletion(b, name, fn, nodes)
+	case "fish":
+		writeFishCompletion(b, name, fn, nodes)
+	default:
+		return &UnsupportedShellError{Shell: shell}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeBashCompletion(b *strings.Builder, name string, fn string, nodes []completionNode) {
+	fmt.Fprintf(b, "# bash completion for %s\n\n", name)
+	fmt.Fprintf(b, "%s() {\n", fn)
+	fmt.Fprintf(b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\" cmdpath=%s i\n", shellQuote(name))
+
+	if paths := completionPaths(nodes); len(paths) != 0 {
+		fmt.Fprintf(b, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+		fmt.Fprintf(b, "        case \"$cmdpath/${COMP_WORDS[i]}\" in\n")
+		fmt.Fprintf(b, "        %s) cmdpath=\"$cmdpath/${COMP_WORDS[i]}\" ;;\n", strings.Join(paths, "|"))
+		fmt.Fprintf(b, "        esac\n")
+		fmt.Fprintf(b, "    done\n")
+	}
+
+	files, values := completionValueFlags(nodes)
+	if len(files) != 0 || len(values) != 0 {
+		fmt.Fprintf(b, "    case \"$prev\" in\n")
+		if len(files) != 0 {
+			fmt.Fprintf(b, "    %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(files, "|"))
+		}
+		if len(values) != 0 {
+			fmt.Fprintf(b, "    %s) return ;;\n", strings.Join(values, "|"))
+		}
+		fmt.Fprintf(b, "    esac\n")
+	}
+
+	fmt.Fprintf(b, "    case \"$cmdpath\" in\n")
+	for _, node := range nodes {
+		fmt.Fprintf(b, "    %s)\n", shellQuote(node.path))
+		fmt.Fprintf(b, "        if [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(b, "            COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(flagNames(node.flags), " ")))
+		fmt.Fprintf(b, "        else\n")
+		fmt.Fprintf(b, "            COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(itemNames(node.commands), " ")))
+		fmt.Fprintf(b, "        fi ;;\n")
+	}
+	fmt.Fprintf(b, "    esac\n")
+	fmt.Fprintf(b, "}\n\n")
+	fmt.Fprintf(b, "complete -F %s %s\n", fn, shellQuote(name))
+}
+
+func writeZshCompletion(b *strings.Builder, name string, fn string, nodes []completionNode) {
+	fmt.Fprintf(b, "#compdef %s\n\n", name)
+	fmt.Fprintf(b, "%s() {\n", fn)
+	fmt.Fprintf(b, "    local cur=\"${words[CURRENT]}\" prev=\"${words[CURRENT-1]}\" cmdpath=%s i\n", shellQuote(name))
+	fmt.Fprintf(b, "    local -a opts cmds\n")
+
+	if paths := completionPaths(nodes); len(paths) != 0 {
+		fmt.Fprintf(b, "    for ((i = 2; i < CURRENT; i++)); do\n")
+		fmt.Fprintf(b, "        case \"$cmdpath/${words[i]}\" in\n")
+		fmt.Fprintf(b, "        (%s) cmdpath=\"$cmdpath/${words[i]}\" ;;\n", strings.Join(paths, "|"))
+		fmt.Fprintf(b, "        esac\n")
+		fmt.Fprintf(b, "    done\n")
+	}
+
+	files, values := completionValueFlags(nodes)
+	if len(files) != 0 || len(values) != 0 {
+		fmt.Fprintf(b, "    case \"$prev\" in\n")
+		if len(files) != 0 {
+			fmt.Fprintf(b, "    (%s) _files; return ;;\n", strings.Join(files, "|"))
+		}
+		if len(values) != 0 {
+			fmt.Fprintf(b, "    (%s) return ;;\n", strings.Join(values, "|"))
+		}
+		fmt.Fprintf(b, "    esac\n")
+	}
+
+	fmt.Fprintf(b, "    case \"$cmdpath\" in\n")
+	for _, node := range nodes {
+		fmt.Fprintf(b, "    (%s)\n", shellQuote(node.path))
+		fmt.Fprintf(b, "        opts=(")
+		for i, f := range node.flags {
+			if i != 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(shellQuote(zshDescription(f.name, f.help)))
+		}
+		fmt.Fprintf(b, ")\n")
+		fmt.Fprintf(b, "        cmds=(")
+		for i, c := range node.commands {
+			if i != 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(shellQuote(zshDescription(c.name, c.help)))
+		}
+		fmt.Fprintf(b, ") ;;\n")
+	}
+	fmt.Fprintf(b, "    esac\n")
+	fmt.Fprintf(b, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(b, "        _describe -t options option opts\n")
+	fmt.Fprintf(b, "    else\n")
+	fmt.Fprintf(b, "        _describe -t commands command cmds\n")
+	fmt.Fprintf(b, "    fi\n")
+	fmt.Fprintf(b, "}\n\n")
+	fmt.Fprintf(b, "compdef %s %s\n", fn, shellQuote(name))
+}
+
+func writeFishCompletion(b *strings.Builder, name string, fn string, nodes []completionNode) {
+	fmt.Fprintf(b, "# fish completion for %s\n\n", name)
+	fmt.Fprintf(b, "function %s_cmdpath\n", fn)
+	fmt.Fprintf(b, "    set -l cmdpath %s\n", fishQuote(name))
+
+	if paths := completionPaths(nodes); len(paths) != 0 {
+		fmt.Fprintf(b, "    set -l words (commandline -opc)\n")
+		fmt.Fprintf(b, "    for w in $words[2..-1]\n")
+		fmt.Fprintf(b, "        switch \"$cmdpath/$w\"\n")
+		fmt.Fprintf(b, "            case %s\n", strings.Join(paths, " "))
+		fmt.Fprintf(b, "                set cmdpath \"$cmdpath/$w\"\n")
+		fmt.Fprintf(b, "        end\n")
+		fmt.Fprintf(b, "    end\n")
+	}
+
+	fmt.Fprintf(b, "    echo $cmdpath\n")
+	fmt.Fprintf(b, "end\n\n")
+	fmt.Fprintf(b, "complete -c %s -f\n", fishQuote(name))
+
+	for _, node := range nodes {
+		cond := fishQuote("test (" + fn + "_cmdpath) = " + fishQuote(node.path))
+
+		for _, c := range node.commands {
+			fmt.Fprintf(b, "complete -c %s -n %s -a %s", fishQuote(name), cond, fishQuote(c.name))
+			if len(c.help) != 0 {
+				fmt.Fprintf(b, " -d %s", fishQuote(c.help))
+			}
+			b.WriteByte('\n')
+		}
+
+		for _, f := range node.flags {
+			// Flags with two dashes are long options for fish, and flags of a
+			// single character are short options.
+			switch {
+			case strings.HasPrefix(f.name, "--"):
+				fmt.Fprintf(b, "complete -c %s -n %s -l %s", fishQuote(name), cond, fishQuote(f.name[2:]))
+			case len(f.name) == 2:
+				fmt.Fprintf(b, "complete -c %s -n %s -s %s", fishQuote(name), cond, fishQuote(f.name[1:]))
+			default:
+				fmt.Fprintf(b, "complete -c %s -n %s -o %s", fishQuote(name), cond, fishQuote(f.name[1:]))
+			}
+			switch {
+			case f.file:
+				b.WriteString(" -r -F")
+			case !f.boolean:
+				b.WriteString(" -r")
+			}
+			if len(f.help) != 0 {
+				fmt.Fprintf(b, " -d %s", fishQuote(f.help))
+			}
+			b.WriteByte('\n')
+		}
+	}
+}
+
+// completionPaths returns the quoted paths of the commands that are not the
+// root of the program.
+func completionPaths(nodes []completionNode) (paths []string) {
+	for _, node := range nodes[1:] {
+		paths = append(paths, shellQuote(node.path))
+	}
+	return
+}
+
+// completionValueFlags returns the names of the flags which take a file path
+// and of those which take another kind of value.
+func completionValueFlags(nodes []completionNode) (files []string, values []string) {
+	seen := make(map[string]bool)
+
+	for _, node := range nodes {
+		for _, f := range node.flags {
+			if seen[f.name] || f.boolean {
+				continue
+			}
+			seen[f.name] = true
+			if f.file {
+				files = append(files, shellQuote(f.name))
+			} else {
+				values = append(values, shellQuote(f.name))
+			}
+		}
+	}
+
+	return
+}
+
+func flagNames(flags []completionFlag) []string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = f.name
+	}
+	return names
+}
+
+func itemNames(items []completionItem) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.name
+	}
+	return names
+}
+
+// completionFuncName returns the name of the shell function completing the
+// program of the given name.
+func completionFuncName(name string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name) + "_completion"
+}
+
+func zshDescription(name string, help string) string {
+	name = strings.ReplaceAll(name, ":", `\:`)
+	if len(help) == 0 {
+		return name
+	}
+	return name + ":" + help
+}
+
+// shellQuote quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
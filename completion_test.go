@@ -0,0 +1,191 @@
+package conf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompletionRequest(t *testing.T) {
+	var cfg struct {
+		Name string `conf:"name" validate:"nonzero"`
+	}
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-completion", "bash"}, "generation of a bash completion script was requested"},
+		{[]string{"--completion=zsh", "ignored"}, "generation of a zsh completion script was requested"},
+		{[]string{"-completion"}, "flag needs an argument: -completion"},
+		{[]string{"-completion", "csh"}, "unsupported shell: csh"},
+		{[]string{"completion", "bash"}, "generation of a bash completion script was requested"},
+		{[]string{"run", "-completion", "fish"}, "flag provided but not defined: -completion"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			ld := Loader{
+				Name:       "test",
+				Args:       test.args,
+				Commands:   []Command{{"run", ""}},
+				Completion: true,
+			}
+
+			if _, _, err := ld.Load(&cfg); err == nil || err.Error() != test.err {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+
+	t.Run("Command", func(t *testing.T) {
+		test := &commandTreeTest{}
+		ld := Loader{
+			Name:       "test",
+			Args:       []string{"-completion", "fish"},
+			Completion: true,
+		}
+
+		if err := ld.Run(context.Background(), test.tree()); err == nil || err.Error() != "generation of a fish completion script was requested" {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("UnsupportedShell", func(t *testing.T) {
+		ld := Loader{Name: "test", Args: []string{"-completion", "csh"}, Completion: true}
+
+		var e *UnsupportedShellError
+		if _, _, err := ld.Load(&cfg); !errors.As(err, &e) || e.Shell != "csh" {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("Arguments", func(t *testing.T) {
+		var cfg struct{}
+
+		ld := Loader{Name: "test", Args: []string{"completion", "foo"}, Completion: true}
+
+		if _, args, err := ld.Load(&cfg); err != nil || !reflect.DeepEqual(args, []string{"completion", "foo"}) {
+			t.Error("bad arguments:", args, err)
+		}
+
+		ld.Commands = []Command{{"completion", ""}, {"run", ""}}
+
+		if cmd, args, err := ld.Load(&cfg); err != nil || cmd != "completion" || !reflect.DeepEqual(args, []string{"foo"}) {
+			t.Error("bad command:", cmd, args, err)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		ld := Loader{
+			Name: "test",
+			Args: []string{"-completion", "bash"},
+		}
+
+		if _, _, err := ld.Load(&cfg); err == nil || err.Error() != "flag provided but not defined: -completion" {
+			t.Error("bad error:", err)
+		}
+	})
+}
+
+func TestFprintCompletion(t *testing.T) {
+	var cfg struct {
+		Verbose bool `conf:"verbose" help:"Enable verbose output"`
+		DB      struct {
+			Host string `conf:"host" help:"Database host"`
+		} `conf:"db"`
+	}
+
+	ld := Loader{
+		Name:     "test",
+		Commands: []Command{{"run", "Run the program"}},
+		Sources:  []Source{NewFileSource("config-file", nil, os.ReadFile, nil)},
+	}
+
+	t.Run("bash", func(t *testing.T) {
+		b := &bytes.Buffer{}
+
+		if err := ld.FprintCompletion(b, &cfg, "bash"); err != nil {
+			t.Fatal(err)
+		}
+
+		const txt = `# bash completion for test
+
+_test_completion() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmdpath='test' i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "$cmdpath/${COMP_WORDS[i]}" in
+        'test/run') cmdpath="$cmdpath/${COMP_WORDS[i]}" ;;
+        esac
+    done
+    case "$prev" in
+    '-config-file') compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- "$cur")); return ;;
+    '-db'|'-db.host') return ;;
+    esac
+    case "$cmdpath" in
+    'test')
+        if [[ "$cur" == -* ]]; then
+            COMPREPLY=($(compgen -W '-config-file -db -db.host -verbose' -- "$cur"))
+        else
+            COMPREPLY=($(compgen -W 'run' -- "$cur"))
+        fi ;;
+    'test/run')
+        if [[ "$cur" == -* ]]; then
+            COMPREPLY=($(compgen -W '-config-file -db -db.host -verbose' -- "$cur"))
+        else
+            COMPREPLY=($(compgen -W '' -- "$cur"))
+        fi ;;
+    esac
+}
+
+complete -F _test_completion 'test'
+`
+
+		if s := b.String(); s != txt {
+			t.Error(s)
+		}
+	})
+
+	t.Run("zsh", func(t *testing.T) {
+		b := &bytes.Buffer{}
+
+		if err := ld.FprintCompletion(b, &cfg, "zsh"); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{
+			"#compdef test\n",
+			"    ('-config-file') _files; return ;;\n",
+			"        opts=('-config-file:Location to load the configuration file from, may be repeated to merge multiple files.' '-db' '-db.host:Database host' '-verbose:Enable verbose output')\n",
+			"        cmds=('run:Run the program') ;;\n",
+			"compdef _test_completion 'test'\n",
+		} {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%q not found in:\n%s", s, b.String())
+			}
+		}
+	})
+
+	t.Run("fish", func(t *testing.T) {
+		b := &bytes.Buffer{}
+
+		if err := ld.FprintCompletion(b, &cfg, "fish"); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{
+			"complete -c 'test' -n 'test (_test_completion_cmdpath) = \\'test\\'' -a 'run' -d 'Run the program'\n",
+			"complete -c 'test' -n 'test (_test_completion_cmdpath) = \\'test/run\\'' -o 'config-file' -r -F -d 'Location to load the configuration file from, may be repeated to merge multiple files.'\n",
+			"complete -c 'test' -n 'test (_test_completion_cmdpath) = \\'test/run\\'' -o 'db.host' -r -d 'Database host'\n",
+			"complete -c 'test' -n 'test (_test_completion_cmdpath) = \\'test/run\\'' -o 'verbose' -d 'Enable verbose output'\n",
+		} {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%q not found in:\n%s", s, b.String())
+			}
+		}
+	})
+}
@@ -15,46 +15,80 @@ import (
 // A prefix may be set to namespace the environment variables that the source
 // will be looking at.
 func NewKubernetesConfigMapSource(prefix string, dir string) Source {
-	base := make([]string, 0, 10)
-	if prefix != "" {
-		base = append(base, prefix)
+	return &configMapSource{
+		prefix: prefix,
+		dir:    dir,
 	}
-	return SourceFunc(func(dst Map) error {
-		f, err := os.Open(dir)
-		if err != nil {
-			return err
+}
+
+type configMapSource struct {
+	prefix string
+	dir    string
+	// names of the files that were found in the directory by the last call to
+	// Load, indexed by the variable name they were matched with
+	names map[string]string
+}
+
+func (c *configMapSource) Load(dst Map) error {
+	f, err := os.Open(c.dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := f.Readdirnames(0)
+	if err != nil {
+		return err
+	}
+	vars := make(map[string]string, 0)
+	names := make(map[string]string, 0)
+	for _, entry := range entries {
+		if len(entry) > 0 && entry[0] == '.' {
+			continue
 		}
-		defer f.Close()
-		entries, err := f.Readdirnames(0)
+		path := filepath.Join(f.Name(), entry)
+		data, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
-		vars := make(map[string]string, 0)
-		for _, entry := range entries {
-			if len(entry) > 0 && entry[0] == '.' {
-				continue
+		vars[snakecaseUpper(entry)] = string(bytes.TrimSuffix(data, []byte{'\n'}))
+		names[snakecaseUpper(entry)] = entry
+	}
+	c.names = names
+	var errs errorList
+	dst.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+		k := c.name(path)
+		if v, ok := vars[k]; ok {
+			// this only matches at the very end
+			key := Origin{Source: "configmap", Key: names[k], File: c.dir}
+			if err := setValue(path[:len(path)-1], item, v, key); err != nil {
+				errs = append(errs, err)
+			} else {
+				dst.loaded(path)
 			}
-			path := filepath.Join(f.Name(), entry)
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return err
+			if len(deprecated) != 0 {
+				dst.deprecated(key, deprecated)
 			}
-			vars[snakecaseUpper(entry)] = string(bytes.TrimSuffix(data, []byte{'\n'}))
 		}
-		dst.Scan(func(path []string, item MapItem) {
-			path = append(base, path...)
-			path = append(path, item.Name)
-
-			k := snakecaseUpper(strings.Join(path, "_"))
-			if v, ok := vars[k]; ok {
-				// this only matches at the very end
-				if e := item.Value.Set(v); e != nil {
-					err = e
-				}
-			}
-		})
-		return nil
 	})
+	return errs.err()
+}
+
+func (c *configMapSource) Origin(path []string) Origin {
+	name := c.names[c.name(path)]
+	return Origin{Source: "configmap", Key: name, File: filepath.Join(c.dir, name)}
+}
+
+func (c *configMapSource) Key(path []string) Origin {
+	return Origin{Source: "configmap", Key: c.name(path), File: c.dir}
+}
+
+// name returns the variable name that the source looks up for the
+// configuration field at path.
+func (c *configMapSource) name(path []string) string {
+	if c.prefix != "" {
+		path = append([]string{c.prefix}, path...)
+	}
+	return snakecaseUpper(strings.Join(path, "_"))
 }
 
 type Subscriber interface {
@@ -2,6 +2,7 @@ package conf
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
@@ -61,6 +62,26 @@ func TestConfigMap(t *testing.T) {
 			t.Errorf("loading config with prefix did not work correctly")
 		}
 	})
+
+	t.Run("InvalidValue", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "port"), []byte("abc\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		a := struct {
+			Port int
+		}{}
+		loader := Loader{
+			Name:    "name",
+			Args:    []string{},
+			Sources: []Source{NewKubernetesConfigMapSource("", dir)},
+		}
+		_, _, err := loader.Load(&a)
+		var e *ParseError
+		if !errors.As(err, &e) || e.Source != "configmap" || e.Key != "port" || e.File != dir || e.Raw != "abc" {
+			t.Error("bad error:", err)
+		}
+	})
 }
 
 func TestSubscriber(t *testing.T) {
@@ -0,0 +1,54 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Deprecation describes the use of a deprecated name to set a configuration
+// field, either one of the aliases of a field which has a "deprecated" tag, or
+// the name of a deprecated field which has no aliases.
+type Deprecation struct {
+	Key     Origin // where the deprecated name was used
+	Message string // message of the field's "deprecated" tag
+}
+
+// String returns a warning message describing d.
+func (d Deprecation) String() string {
+	return keyDescription(d.Key) + " is deprecated: " + d.Message
+}
+
+// deprecated reports d to the OnDeprecated function of ld, if it is set.
+func (ld Loader) deprecated(d Deprecation) {
+	if ld.OnDeprecated != nil {
+		ld.OnDeprecated(d)
+	}
+}
+
+// printDeprecation is the OnDeprecated function of loaders used by LoadWith and
+// RunWith when they have none, it prints d as a warning to stderr.
+func printDeprecation(d Deprecation) {
+	fmt.Fprintf(os.Stderr, "warning: %s\n", d)
+}
+
+// deprecatedFlags returns the names of the flags setting the fields of m that
+// must be reported as deprecated, with their deprecation messages.
+func deprecatedFlags(m Map) map[string]string {
+	flags := make(map[string]string)
+
+	m.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+		if len(deprecated) != 0 {
+			flags[strings.Join(path, ".")] = deprecated
+		}
+	})
+
+	// Short flags are deprecated when the long flag they stand for is.
+	scanShorts(m, nil, func(path []string, item MapItem) {
+		if msg, ok := flags[strings.Join(path, ".")]; ok {
+			flags[item.Short] = msg
+		}
+	})
+
+	return flags
+}
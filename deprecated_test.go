@@ -0,0 +1,171 @@
+package conf
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type aliasConfig struct {
+	Name string `conf:"name" alias:"app-name,app_name" deprecated:"use -name instead"`
+	DB   struct {
+		Host string `conf:"host" alias:"hostname"`
+	} `conf:"db" alias:"database"`
+	Legacy bool `conf:"legacy" help:"Enable the legacy mode" deprecated:"it will be removed in the next release"`
+}
+
+func TestAliases(t *testing.T) {
+	const path = "/tmp/conf-test-aliases.yaml"
+	os.WriteFile(path, []byte("app_name: file\ndatabase: {hostname: db.file}\n"), 0644)
+	defer os.Remove(path)
+
+	tests := []struct {
+		name string
+		args []string
+		env  []string
+		cfg  func(*aliasConfig)
+	}{
+		{
+			name: "Flags",
+			args: []string{"-app-name", "flag", "-database.hostname", "db.flag"},
+			cfg:  func(c *aliasConfig) { c.Name, c.DB.Host = "flag", "db.flag" },
+		},
+		{
+			name: "Env",
+			env:  []string{"TEST_APP_NAME=env", "TEST_DB_HOSTNAME=db.env"},
+			cfg:  func(c *aliasConfig) { c.Name, c.DB.Host = "env", "db.env" },
+		},
+		{
+			name: "EnvPrecedence",
+			args: []string{"-config-file", path},
+			env:  []string{"TEST_NAME=name", "TEST_APP_NAME=env"},
+			cfg:  func(c *aliasConfig) { c.Name, c.DB.Host = "name", "db.file" },
+		},
+		{
+			name: "File",
+			args: []string{"-config-file", path},
+			cfg:  func(c *aliasConfig) { c.Name, c.DB.Host = "file", "db.file" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cfg, expected aliasConfig
+			test.cfg(&expected)
+
+			ld := Loader{
+				Name: "test",
+				Args: test.args,
+				Sources: []Source{
+					NewFileSource("config-file", nil, os.ReadFile, nil),
+					NewEnvSource("test", test.env...),
+				},
+				OnDeprecated: func(Deprecation) {},
+			}
+
+			if _, _, err := ld.Load(&cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("bad configuration: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestAliasesOrigins(t *testing.T) {
+	var cfg aliasConfig
+
+	ld := Loader{
+		Name:         "test",
+		Args:         []string{"-app-name", "flag", "-database.hostname", "db.flag"},
+		Sources:      []Source{NewEnvSource("test", "TEST_NAME=env", "TEST_DB_HOST=db.env")},
+		OnDeprecated: func(Deprecation) {},
+	}
+
+	_, _, origins, err := ld.LoadWithOrigins(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Name != "flag" || cfg.DB.Host != "db.flag" {
+		t.Errorf("bad configuration: %+v", cfg)
+	}
+
+	expected := map[string]Origin{
+		"name":    {Source: "flag", Key: "-app-name"},
+		"db.host": {Source: "flag", Key: "-database.hostname"},
+		"legacy":  {Source: "default"},
+	}
+
+	if !reflect.DeepEqual(origins, expected) {
+		t.Errorf("bad origins:\n<<< %#v\n>>> %#v", expected, origins)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	const path = "/tmp/conf-test-deprecated.json"
+	os.WriteFile(path, []byte(`{"name":"a","app_name":"b","legacy":true}`), 0644)
+	defer os.Remove(path)
+
+	var cfg aliasConfig
+	var warnings []string
+
+	ld := Loader{
+		Name: "test",
+		Args: []string{"-config-file", path, "-app-name", "c", "-database.host", "localhost"},
+		Sources: []Source{
+			NewFileSource("config-file", nil, os.ReadFile, nil),
+			NewEnvSource("test", "TEST_APP_NAME=d", "TEST_NAME=e"),
+		},
+		OnDeprecated: func(d Deprecation) {
+			warnings = append(warnings, d.String())
+		},
+	}
+
+	if _, _, err := ld.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"the app_name key of /tmp/conf-test-deprecated.json is deprecated: use -name instead",
+		"the legacy key of /tmp/conf-test-deprecated.json is deprecated: it will be removed in the next release",
+		"the TEST_APP_NAME environment variable is deprecated: use -name instead",
+		"the -app-name flag is deprecated: use -name instead",
+	}
+
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("bad warnings:\n%s", strings.Join(warnings, "\n"))
+	}
+}
+
+func TestPrintHelpAliases(t *testing.T) {
+	var cfg aliasConfig
+	b := &bytes.Buffer{}
+
+	(Loader{Name: "test"}).FprintHelp(b, &cfg)
+
+	const txt = "Usage:\n" +
+		"  test [-h] [-help] [options...]\n" +
+		"\n" +
+		"Options:\n" +
+		"  -db object\n" +
+		"    \t(alias -database)\n" +
+		"\n" +
+		"  -db.host string\n" +
+		"    \t(alias -db.hostname)\n" +
+		"\n" +
+		"  -legacy\n" +
+		"    \tEnable the legacy mode (deprecated: it will be removed in the next release)\n" +
+		"\n" +
+		"  -name string\n" +
+		"    \t(deprecated alias -app-name, -app_name: use -name instead)\n" +
+		"\n"
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
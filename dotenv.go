@@ -0,0 +1,218 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/segmentio/objconv"
+)
+
+// NewDotenvSource creates a new source which loads values from the dotenv file
+// at path, a missing file is ignored.
+//
+// The variables found in the file are matched against the configuration fields
+// the same way than NewEnvSource does, prefix may be set to namespace them. The
+// file is only read by the source, the variables are not exported to the
+// environment of the program.
+//
+// Each line of the file sets a variable with the KEY=VALUE syntax, optionally
+// preceded by "export". Values may be single-quoted (taken literally) or
+// double-quoted (supporting \n, \r, \t, \", \\ and \$ escapes), quoted values
+// may span multiple lines. Lines starting with '#' and the text following a
+// '#' preceded by a space in unquoted values are comments.
+func NewDotenvSource(prefix string, path string) Source {
+	return &dotenvSource{
+		env:  envSource{prefix: prefix},
+		path: path,
+	}
+}
+
+type dotenvSource struct {
+	env  envSource
+	path string
+}
+
+func (d *dotenvSource) Load(dst Map) error {
+	return d.loadFile(dst, false)
+}
+
+// loadStrict loads the dotenv file like Load, and also reports the variables
+// of the file which don't match any field. The file is dedicated to the program
+// so all its variables are checked when the source has no prefix.
+func (d *dotenvSource) loadStrict(dst Map) error {
+	return d.loadFile(dst, true)
+}
+
+func (d *dotenvSource) loadFile(dst Map, strict bool) error {
+	b, err := os.ReadFile(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if d.env.vars, err = parseDotenv(b); err != nil {
+		return fmt.Errorf("%s: %w", d.path, err)
+	}
+
+	errs := errorList{}.append(d.env.load(dst, d.Key))
+
+	if strict {
+		errs = errs.append(d.env.unknownVars(dst, "dotenv", d.path))
+	}
+
+	return errs.err()
+}
+
+func (d *dotenvSource) Origin(path []string) Origin {
+	return d.Key(path)
+}
+
+func (d *dotenvSource) Key(path []string) Origin {
+	return Origin{Source: "dotenv", Key: d.env.name(path), File: d.path}
+}
+
+// UnmarshalDotenv decodes the dotenv document in b into v, using the syntax
+// described by NewDotenvSource.
+//
+// When v is a Map the variables are matched against the configuration fields
+// the same way than the environment source does (without a prefix), for
+// example DB_HOST sets the db.host field. Other values are decoded from a map
+// of the variable names to their values.
+func UnmarshalDotenv(b []byte, v interface{}) error {
+	vars, err := parseDotenv(b)
+	if err != nil {
+		return err
+	}
+
+	if m, ok := v.(Map); ok {
+		return (&envSource{vars: vars}).Load(m)
+	}
+
+	return (objconv.Decoder{Parser: objconv.NewValueParser(vars)}).Decode(v)
+}
+
+func parseDotenv(b []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if rest := strings.TrimPrefix(line, "export"); len(rest) != len(line) && len(rest) != 0 && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
+
+		off := strings.IndexByte(line, '=')
+		if off < 0 {
+			return nil, fmt.Errorf("dotenv: line %d: missing '=' after variable name", n)
+		}
+
+		key, value := strings.TrimSpace(line[:off]), strings.TrimLeft(line[off+1:], " \t")
+
+		if len(key) == 0 || strings.ContainsAny(key, " \t\"'") {
+			return nil, fmt.Errorf("dotenv: line %d: invalid variable name: %q", n, key)
+		}
+
+		if len(value) == 0 || (value[0] != '"' && value[0] != '\'') {
+			vars[key] = trimDotenvComment(value)
+			continue
+		}
+
+		// Quoted values continue on the next lines until the closing quote is
+		// found.
+		quote := value[0]
+		value = value[1:]
+
+		for {
+			end := closingQuote(value, quote)
+			if end >= 0 {
+				if rest := strings.TrimSpace(value[end+1:]); len(rest) != 0 && rest[0] != '#' {
+					return nil, fmt.Errorf("dotenv: line %d: unexpected characters after quoted value: %q", i+1, rest)
+				}
+				value = value[:end]
+				break
+			}
+			if i++; i == len(lines) {
+				return nil, fmt.Errorf("dotenv: line %d: unterminated quoted value", n)
+			}
+			value += "\n" + lines[i]
+		}
+
+		if quote == '"' {
+			value = unescapeDotenv(value)
+		}
+
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+// closingQuote returns the index of the quote character closing the value s,
+// or -1 if s contains no closing quote. Double quotes may be escaped with a
+// backslash.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func trimDotenvComment(s string) string {
+	if strings.HasPrefix(s, "#") {
+		return ""
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+func unescapeDotenv(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	b := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '\\' && i+1 < len(s) {
+			i++
+			switch c = s[i]; c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case '"', '\\', '$':
+			default:
+				b = append(b, '\\')
+			}
+		}
+
+		b = append(b, c)
+	}
+
+	return string(b)
+}
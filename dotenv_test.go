@@ -0,0 +1,120 @@
+package conf
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	const env = `# comment
+A=1
+export B = two words # trailing comment
+C=no#comment
+D='single $quoted \n'
+E="double \"quoted\"\t\$HOME\\"
+F="multi
+line"
+G='multi
+line'
+H=
+I="a" # comment
+export=J
+`
+
+	vars, err := parseDotenv([]byte(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"A":      "1",
+		"B":      "two words",
+		"C":      "no#comment",
+		"D":      `single $quoted \n`,
+		"E":      "double \"quoted\"\t$HOME\\",
+		"F":      "multi\nline",
+		"G":      "multi\nline",
+		"H":      "",
+		"I":      "a",
+		"export": "J",
+	}
+
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("bad variables:\n<<< %#v\n>>> %#v", expected, vars)
+	}
+
+	for _, test := range []struct {
+		env string
+		err string
+	}{
+		{"A", "dotenv: line 1: missing '=' after variable name"},
+		{"\nA B=1", `dotenv: line 2: invalid variable name: "A B"`},
+		{"A=\"1\nB=2\n", "dotenv: line 1: unterminated quoted value"},
+		{"A='1' 2", `dotenv: line 1: unexpected characters after quoted value: "2"`},
+	} {
+		if _, err := parseDotenv([]byte(test.env)); err == nil || err.Error() != test.err {
+			t.Errorf("%q: bad error: %v", test.env, err)
+		}
+	}
+}
+
+func TestDotenvSource(t *testing.T) {
+	const path = "/tmp/conf-test.env"
+	os.WriteFile(path, []byte("TEST_NAME=\"hello\\nworld\"\nexport TEST_DB_HOST=localhost\nOTHER=1\n"), 0644)
+	defer os.Remove(path)
+
+	var cfg struct {
+		Name string `conf:"name"`
+		DB   struct {
+			Host string `conf:"host"`
+		} `conf:"db"`
+	}
+
+	ld := Loader{
+		Name: "test",
+		Sources: []Source{
+			NewDotenvSource("test", "/tmp/conf-test-missing.env"),
+			NewDotenvSource("test", path),
+		},
+	}
+
+	_, _, origins, err := ld.LoadWithOrigins(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Name != "hello\nworld" || cfg.DB.Host != "localhost" {
+		t.Errorf("bad configuration: %+v", cfg)
+	}
+
+	if o := origins["db.host"].String(); o != "dotenv /tmp/conf-test.env (TEST_DB_HOST)" {
+		t.Error("bad origin:", o)
+	}
+
+	if _, ok := os.LookupEnv("TEST_DB_HOST"); ok {
+		t.Error("the dotenv source must not modify the environment")
+	}
+}
+
+func TestDotenvSourceStrict(t *testing.T) {
+	const path = "/tmp/conf-test-strict.env"
+	os.WriteFile(path, []byte("NAME=hello\nNAEM=world\n"), 0644)
+	defer os.Remove(path)
+
+	var cfg struct {
+		Name string `conf:"name"`
+	}
+
+	ld := Loader{
+		Name:    "test",
+		Sources: []Source{NewDotenvSource("", path)},
+		Strict:  true,
+	}
+
+	_, _, err := ld.Load(&cfg)
+
+	if err == nil || err.Error() != "unknown variable NAEM in /tmp/conf-test-strict.env, did you mean NAME?" {
+		t.Error("bad error:", err)
+	}
+}
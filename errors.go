@@ -0,0 +1,228 @@
+package conf
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/validator.v2"
+)
+
+// ErrMissingCommand is returned when a program expects a command but none was
+// passed in its arguments.
+var ErrMissingCommand = errors.New("missing command")
+
+// ParseError is returned when a value loaded from a source cannot be parsed
+// into the type of the configuration field that it was set on.
+type ParseError struct {
+	Path   string // path of the configuration field, like "db.port"
+	Source string // kind of source the value was loaded from, like "env"
+	Key    string // key of the value in the source, like "MYAPP_DB_PORT" or "-db.port"
+	File   string // file the value was loaded from, if any
+	Raw    string // value that failed to be parsed, redacted for secrets
+	Err    error  // error that occurred while parsing the value
+}
+
+func (e *ParseError) Error() string {
+	return "invalid value found in " + keyDescription(Origin{Source: e.Source, Key: e.Key, File: e.File}) + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// setValue sets the value of item, which is found at path in the configuration,
+// to raw. The returned error is a *ParseError built from key if the value could
+// not be parsed.
+func setValue(path []string, item MapItem, raw string, key Origin) error {
+	err := item.Value.Set(raw)
+	if err == nil {
+		return nil
+	}
+
+	if item.Secret || isSecretNode(item.Value) {
+		err = redactError(err, raw)
+		raw = redacted
+	}
+
+	return &ParseError{
+		Path:   strings.Join(append(path[:len(path):len(path)], item.Name), "."),
+		Source: key.Source,
+		Key:    key.Key,
+		File:   key.File,
+		Raw:    raw,
+		Err:    err,
+	}
+}
+
+func isSecretNode(node Node) bool {
+	s, ok := node.(Scalar)
+	return ok && s.isSecret()
+}
+
+// redactError returns an error with the same message than err, except for the
+// occurrences of the secret value which are redacted. Secrets shorter than
+// minRedactedLength would match unrelated parts of the message, so when the
+// message contains one of them it is replaced as a whole instead.
+func redactError(err error, value string) error {
+	if len(value) == 0 {
+		return err
+	}
+	msg := err.Error()
+	switch {
+	case !strings.Contains(msg, value):
+		return err
+	case len(value) < minRedactedLength:
+		return &redactedError{msg: "invalid secret value", err: err}
+	default:
+		return &redactedError{msg: strings.ReplaceAll(msg, value, redacted), err: err}
+	}
+}
+
+// redactedError is the error returned by redactError, it wraps the original
+// error so it can still be matched with errors.Is and errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// minRedactedLength is the length of the shortest secret that redactError
+// replaces within error messages.
+const minRedactedLength = 6
+
+// ValidationError is returned when the value of a configuration field doesn't
+// satisfy one of the rules of its validate tag.
+type ValidationError struct {
+	Path string // path of the configuration field, like "db.port"
+	Rule string // name of the rule that failed, like "min" or "nonzero"
+	Err  error  // error returned by the validator
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid value passed to " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validationRules maps the errors of the builtin validators to the name of
+// their rule.
+var validationRules = map[error]string{
+	validator.ErrZeroValue: "nonzero",
+	validator.ErrMin:       "min",
+	validator.ErrMax:       "max",
+	validator.ErrLen:       "len",
+	validator.ErrRegexp:    "regexp",
+}
+
+// failedRules returns the names of the rules in tag that produced errs. The
+// validator reports errors in the order of the rules, so each error is matched
+// with the next rule of the same kind: builtin errors with the rule they are
+// returned by, and other errors with the next custom rule.
+func failedRules(tag string, errs []error) []string {
+	rules := make([]string, len(errs))
+	names := make([]string, 0, 8)
+
+	for _, rule := range splitRules(tag) {
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			rule = rule[:i]
+		}
+		names = append(names, strings.TrimSpace(rule))
+	}
+
+	for i, err := range errs {
+		builtin, isBuiltin := validationRules[err]
+
+		for j, name := range names {
+			if (isBuiltin && name == builtin) || (!isBuiltin && !isBuiltinRule(name)) {
+				rules[i], names = name, names[j+1:]
+				break
+			}
+		}
+	}
+
+	return rules
+}
+
+func isBuiltinRule(name string) bool {
+	for _, rule := range validationRules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownKeyError is returned by loaders in strict mode when a source has a
+// value which doesn't match any configuration field.
+type UnknownKeyError struct {
+	Source     string // kind of source the key was found in, like "file"
+	Key        string // the unknown key, like "db.max_conections"
+	File       string // file the key was found in, if any
+	Suggestion string // closest valid key, if one looks like a typo of Key
+}
+
+func (e *UnknownKeyError) Error() string {
+	s := "unknown key " + e.Key
+
+	switch e.Source {
+	case "env":
+		s = "unknown environment variable " + e.Key
+	case "dotenv":
+		s = "unknown variable " + e.Key
+	}
+
+	if len(e.File) != 0 {
+		s += " in " + e.File
+	}
+
+	if len(e.Suggestion) != 0 {
+		s += ", did you mean " + e.Suggestion + "?"
+	}
+
+	return s
+}
+
+// UnknownCommandError is returned when the command passed in the arguments of
+// a program does not exist.
+type UnknownCommandError struct {
+	Name       string // name of the unknown command
+	Suggestion string // closest command, if one looks like a typo of Name
+}
+
+func (e *UnknownCommandError) Error() string {
+	s := "unknown command: " + e.Name
+
+	if len(e.Suggestion) != 0 {
+		s += ", did you mean " + e.Suggestion + "?"
+	}
+
+	return s
+}
+
+// UnknownFlagError is returned when the arguments of a program contain a flag
+// which is not defined.
+type UnknownFlagError struct {
+	Name       string // name of the unknown flag, without leading dashes
+	Suggestion string // closest flag, if one looks like a typo of Name
+
+	gnu bool // long flags were passed with two dashes
+}
+
+func (e *UnknownFlagError) Error() string {
+	s := "flag provided but not defined: " + flagName(e.Name, e.gnu)
+
+	if len(e.Suggestion) != 0 {
+		s += ", did you mean " + flagName(e.Suggestion, e.gnu) + "?"
+	}
+
+	return s
+}
@@ -0,0 +1,276 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/validator.v2"
+)
+
+func TestParseError(t *testing.T) {
+	var cfg struct {
+		DB struct {
+			Port     int    `conf:"port"`
+			Password Secret `conf:"password"`
+		} `conf:"db"`
+		Key int `conf:"key" secret:"true"`
+	}
+
+	ld := Loader{
+		Name:    "myapp",
+		Sources: []Source{NewEnvSource("myapp", "MYAPP_DB_PORT=abc", "MYAPP_KEY=hunter2")},
+	}
+
+	_, _, err := ld.Load(&cfg)
+
+	var errs []*ParseError
+	for _, e := range err.(errorList) {
+		var p *ParseError
+		if !errors.As(e, &p) {
+			t.Fatal("bad error:", e)
+		}
+		errs = append(errs, p)
+	}
+
+	if len(errs) != 2 {
+		t.Fatal("bad errors:", err)
+	}
+
+	if e := errs[0]; e.Path != "db.port" || e.Source != "env" || e.Key != "MYAPP_DB_PORT" || e.Raw != "abc" || e.Err == nil {
+		t.Errorf("bad error: %+v", e)
+	}
+
+	if e := errs[1]; e.Path != "key" || e.Key != "MYAPP_KEY" || e.Raw != redacted || strings.Contains(e.Error(), "hunter2") {
+		t.Errorf("bad error: %+v", e)
+	}
+
+	var nerr *strconv.NumError
+	if !errors.As(errs[1], &nerr) {
+		t.Error("errors.As did not find the strconv error of the secret value")
+	}
+
+	if s := errs[0].Error(); s != "invalid value found in the MYAPP_DB_PORT environment variable: "+errs[0].Err.Error() {
+		t.Error("bad error message:", s)
+	}
+}
+
+func TestParseErrorFlags(t *testing.T) {
+	var cfg struct {
+		Tags []string `conf:"tags" alias:"labels"`
+		Port int      `conf:"port" short:"p"`
+		Key  int      `conf:"key" secret:"true"`
+	}
+
+	tests := []struct {
+		args []string
+		gnu  bool
+		err  ParseError
+	}{
+		{
+			args: []string{"-tags", "["},
+			err:  ParseError{Path: "tags", Source: "flag", Key: "-tags", Raw: "["},
+		},
+		{
+			args: []string{"-labels", "["},
+			err:  ParseError{Path: "tags", Source: "flag", Key: "-labels", Raw: "["},
+		},
+		{
+			args: []string{"--port", "abc"},
+			gnu:  true,
+			err:  ParseError{Path: "port", Source: "flag", Key: "--port", Raw: "abc"},
+		},
+		{
+			args: []string{"-p", "abc"},
+			err:  ParseError{Path: "port", Source: "flag", Key: "-p", Raw: "abc"},
+		},
+		{
+			args: []string{"-pabc"},
+			gnu:  true,
+			err:  ParseError{Path: "port", Source: "flag", Key: "-p", Raw: "abc"},
+		},
+		{
+			args: []string{"-key", "hunter2"},
+			err:  ParseError{Path: "key", Source: "flag", Key: "-key", Raw: redacted},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			_, _, err := (Loader{Name: "test", Args: test.args, GNU: test.gnu}).Load(&cfg)
+
+			var e *ParseError
+			if !errors.As(err, &e) {
+				t.Fatal("bad error:", err)
+			}
+
+			if e.Path != test.err.Path || e.Source != test.err.Source || e.Key != test.err.Key || e.Raw != test.err.Raw || e.Err == nil {
+				t.Errorf("bad error: %+v", e)
+			}
+
+			if s := e.Error(); strings.Contains(s, "hunter2") || !strings.HasPrefix(s, "invalid value found in the "+test.err.Key+" flag: ") {
+				t.Error("bad error message:", s)
+			}
+		})
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	tests := []struct {
+		err    string
+		secret string
+		msg    string
+	}{
+		{
+			err:    `strconv.ParseInt: parsing "hunter2": invalid syntax`,
+			secret: "hunter2",
+			msg:    `strconv.ParseInt: parsing "<redacted>": invalid syntax`,
+		},
+		{
+			err:    `strconv.ParseBool: parsing "a": invalid syntax`,
+			secret: "a",
+			msg:    "invalid secret value",
+		},
+		{
+			err:    "value out of range",
+			secret: "1",
+			msg:    "value out of range",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.secret, func(t *testing.T) {
+			err := errors.New(test.err)
+			redacted := redactError(err, test.secret)
+
+			if s := redacted.Error(); s != test.msg {
+				t.Error("bad error message:", s)
+			}
+
+			if !errors.Is(redacted, err) {
+				t.Error("errors.Is did not find the original error")
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	validator.SetValidationFunc("even", func(v interface{}, _ string) error {
+		if reflect.ValueOf(v).Int()%2 != 0 {
+			return errors.New("not even")
+		}
+		return nil
+	})
+
+	var cfg struct {
+		Host  string `conf:"host" validate:"nonzero"`
+		Count int    `conf:"count" validate:"min=10,even,max=20"`
+	}
+	cfg.Count = 3
+
+	_, _, err := (Loader{}).Load(&cfg)
+
+	errs, ok := err.(errorList)
+	if !ok || len(errs) != 3 {
+		t.Fatal("bad error:", err)
+	}
+
+	expected := []ValidationError{
+		{Path: "count", Rule: "min", Err: validator.ErrMin},
+		{Path: "count", Rule: "even"},
+		{Path: "host", Rule: "nonzero", Err: validator.ErrZeroValue},
+	}
+
+	for i, e := range errs {
+		v, ok := e.(*ValidationError)
+		if !ok {
+			t.Fatalf("bad error #%d: %#v", i, e)
+		}
+		if v.Path != expected[i].Path || v.Rule != expected[i].Rule || (expected[i].Err != nil && v.Err != expected[i].Err) {
+			t.Errorf("bad error #%d: %+v", i, v)
+		}
+	}
+
+	if !errors.Is(err, validator.ErrZeroValue) {
+		t.Error("errors.Is did not find the validator error")
+	}
+}
+
+func TestUnknownCommandError(t *testing.T) {
+	ld := Loader{
+		Name:     "test",
+		Args:     []string{"drop"},
+		Commands: []Command{{"run", ""}},
+	}
+
+	_, _, err := ld.Load(nil)
+
+	var e *UnknownCommandError
+	if !errors.As(err, &e) || e.Name != "drop" || e.Suggestion != "" {
+		t.Error("bad error:", err)
+	}
+
+	ld.Args = []string{"rnu"}
+
+	if _, _, err := ld.Load(nil); err == nil || err.Error() != "unknown command: rnu, did you mean run?" {
+		t.Error("bad error:", err)
+	}
+
+	ld.Args = nil
+
+	if _, _, err := ld.Load(nil); err != ErrMissingCommand {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestUnknownFlagError(t *testing.T) {
+	var cfg struct {
+		DB struct {
+			Host string `conf:"host"`
+		} `conf:"db"`
+		Verbose bool `conf:"verbose"`
+	}
+
+	tests := []struct {
+		args  []string
+		name  string
+		error string
+	}{
+		{
+			args:  []string{"-db.hots", "localhost"},
+			name:  "db.hots",
+			error: "flag provided but not defined: -db.hots, did you mean -db.host?",
+		},
+		{
+			args:  []string{"--verbos"},
+			name:  "verbos",
+			error: "flag provided but not defined: -verbos, did you mean -verbose?",
+		},
+		{
+			args:  []string{"-port", "80"},
+			name:  "port",
+			error: "flag provided but not defined: -port",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := (Loader{Name: "test", Args: test.args}).Load(&cfg)
+
+			var e *UnknownFlagError
+			if !errors.As(err, &e) || e.Name != test.name || err.Error() != test.error {
+				t.Error("bad error:", err)
+			}
+
+			b := &bytes.Buffer{}
+			(Loader{}).fprintError(b, err, monochrome())
+
+			if s := b.String(); s != "Error:\n  "+test.error+"\n\n" {
+				t.Error("bad error output:", s)
+			}
+		})
+	}
+}
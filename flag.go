@@ -1,8 +1,10 @@
 package conf
 
 import (
+	"errors"
 	"flag"
 	"io"
+	"reflect"
 	"strings"
 )
 
@@ -10,8 +12,13 @@ func newFlagSet(cfg Map, name string, sources ...Source) *flag.FlagSet {
 	set := flag.NewFlagSet(name, flag.ContinueOnError)
 	set.SetOutput(io.Discard)
 
-	cfg.Scan(func(path []string, item MapItem) {
-		set.Var(item.Value, strings.Join(append(path, item.Name), "."), item.Help)
+	cfg.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+		set.Var(&fieldFlag{
+			name:   strings.Join(path, "."),
+			value:  flagValue(item),
+			path:   flagPath(cfg, strings.Join(path, ".")),
+			secret: item.Secret || isSecretNode(item.Value),
+		}, strings.Join(path, "."), item.Help)
 	})
 
 	for _, source := range sources {
@@ -20,5 +27,399 @@ func newFlagSet(cfg Map, name string, sources ...Source) *flag.FlagSet {
 		}
 	}
 
+	// Short flags share the value of their long flag, so slices get appended
+	// to by both.
+	scanShorts(cfg, nil, func(path []string, item MapItem) {
+		long := strings.Join(path, ".")
+
+		if set.Lookup(item.Short) != nil {
+			panic("short flag -" + item.Short + " of " + long + " conflicts with another flag in configuration")
+		}
+
+		short := *set.Lookup(long).Value.(*fieldFlag)
+		short.name = item.Short
+		set.Var(&short, item.Short, item.Help)
+	})
+
 	return set
 }
+
+// scanShorts calls do with the path of the fields of m which have a short flag
+// name. Fields nested in maps or slices have no short flags since they don't
+// have a single path.
+func scanShorts(m Map, path []string, do func(path []string, item MapItem)) {
+	if m.value.Kind() != reflect.Struct {
+		return
+	}
+
+	for _, item := range m.Items() {
+		p := append(path[:len(path):len(path)], item.Name)
+
+		if len(item.Short) != 0 {
+			do(p, item)
+		}
+
+		if v, ok := item.Value.(Map); ok {
+			scanShorts(v, p, do)
+		}
+	}
+}
+
+// parseFlags parses args with set, errors reporting flags which are not
+// defined are returned as *UnknownFlagError. When gnu is true the arguments
+// follow the GNU conventions, see expandGNUFlags.
+//
+// Flag values which accumulate the arguments are reset first, so parsing the
+// same arguments again does not accumulate them twice.
+func parseFlags(set *flag.FlagSet, args []string, gnu bool) error {
+	set.VisitAll(func(f *flag.Flag) {
+		if r, ok := f.Value.(resetter); ok {
+			r.reset()
+		}
+	})
+
+	if gnu {
+		args = expandGNUFlags(set, args)
+	}
+
+	err := set.Parse(expandMapFlags(set, args))
+	if err == nil {
+		return nil
+	}
+
+	// The flag package formats the errors returned by flag values, the
+	// *ParseError of the flag that failed is returned instead. Parsing stops
+	// at the first error, so it is the only flag with an error.
+	var perr *ParseError
+	set.VisitAll(func(f *flag.Flag) {
+		if v, ok := f.Value.(*fieldFlag); ok && v.err != nil {
+			perr = v.err
+			perr.Key = flagName(v.name, gnu)
+		}
+	})
+	if perr != nil {
+		return perr
+	}
+
+	const prefix = "flag provided but not defined: -"
+
+	if msg := err.Error(); strings.HasPrefix(msg, prefix) {
+		var names []string
+		set.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+
+		name := strings.TrimPrefix(msg[len(prefix):], "-")
+		return &UnknownFlagError{Name: name, Suggestion: suggest(name, names), gnu: gnu}
+	}
+
+	return err
+}
+
+// flagName returns the name of a flag as it is passed in the program arguments,
+// with one dash, or two dashes for long flags in GNU mode.
+func flagName(name string, gnu bool) string {
+	if gnu && len(name) > 1 {
+		return "--" + name
+	}
+	return "-" + name
+}
+
+// fieldFlag is the flag.Value of the configuration field at path, it reports
+// the values which cannot be parsed as *ParseError.
+type fieldFlag struct {
+	name   string      // name of the flag, short flags have their own fieldFlag
+	value  flag.Value  // the node of the field, or a value wrapping it
+	path   string      // path of the field
+	secret bool        // the field holds a secret
+	err    *ParseError // error returned by the last call to Set
+}
+
+func (f *fieldFlag) String() string {
+	return f.value.String()
+}
+
+func (f *fieldFlag) Set(s string) error {
+	err := f.value.Set(s)
+	if err == nil {
+		return nil
+	}
+
+	if f.secret {
+		err = redactError(err, s)
+		s = redacted
+	}
+
+	f.err = &ParseError{Path: f.path, Source: "flag", Raw: s, Err: err}
+	return f.err
+}
+
+func (f *fieldFlag) IsBoolFlag() bool {
+	return isBoolFlag(reflect.ValueOf(f.value))
+}
+
+func (f *fieldFlag) reset() {
+	f.err = nil
+
+	if r, ok := f.value.(resetter); ok {
+		r.reset()
+	}
+}
+
+// node returns the configuration node that the flag sets.
+func (f *fieldFlag) node() Node {
+	return flagNode(f.value.(Node))
+}
+
+// flagValue returns the flag.Value used to set item from the program
+// arguments.
+func flagValue(item MapItem) flag.Value {
+	if !item.Replace {
+		switch v := item.Value.(type) {
+		case Array:
+			return &sliceFlag{Array: v}
+		case Map:
+			if v.value.Kind() == reflect.Map {
+				return mapFlag{Map: v}
+			}
+		}
+	}
+	return item.Value
+}
+
+// flagNode returns the configuration node that a flag value sets.
+func flagNode(v Node) Node {
+	switch f := v.(type) {
+	case *sliceFlag:
+		return f.Array
+	case mapFlag:
+		return f.Map
+	}
+	return v
+}
+
+// sliceFlag is the flag.Value of slice fields. The first occurrence of the flag
+// replaces the value loaded from other sources, then each occurrence appends
+// to it.
+type sliceFlag struct {
+	Array
+	set bool
+}
+
+func (f *sliceFlag) Set(s string) error {
+	// A YAML sequence sets multiple elements at once, anything else is the
+	// value of a single element.
+	list := strings.HasPrefix(strings.TrimSpace(s), "[")
+	v := reflect.New(f.value.Type()).Elem()
+
+	if !list {
+		v = reflect.New(f.value.Type().Elem()).Elem()
+	}
+
+	if err := makeNode(v).Set(s); err != nil {
+		return err
+	}
+
+	if !list {
+		v = reflect.Append(reflect.MakeSlice(f.value.Type(), 0, 1), v)
+	}
+
+	if !f.set {
+		f.pop(f.Len())
+		f.set = true
+	}
+
+	f.append(v)
+	return nil
+}
+
+func (f *sliceFlag) reset() {
+	f.set = false
+}
+
+// mapFlag is the flag.Value of map fields, it sets entries of the map from
+// key=value arguments. The key may be a dotted path to a field of the entry
+// when the values of the map are structs or maps.
+type mapFlag struct {
+	Map
+}
+
+func (f mapFlag) Set(s string) error {
+	// YAML objects are merged into the map like values of the other sources.
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return f.Map.Set(s)
+	}
+
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return errors.New("expected key=value")
+	}
+
+	return setMapPath(f.Map, key, value)
+}
+
+// setMapPath sets the value at path in m, which wraps a map.
+func setMapPath(m Map, path string, value string) error {
+	key, rest := path, ""
+
+	if t := m.value.Type().Elem(); t.Kind() == reflect.Struct || t.Kind() == reflect.Map {
+		key, rest, _ = strings.Cut(path, ".")
+	}
+
+	k, err := parseMapKey(key, m.value.Type().Key())
+	if err != nil {
+		return err
+	}
+
+	return m.setEntry(k, func(node Node) error {
+		return setPath(node, rest, value)
+	})
+}
+
+// setPath sets the value at the dotted path in node.
+func setPath(node Node, path string, value string) error {
+	if len(path) == 0 {
+		return node.Set(value)
+	}
+
+	if m, ok := node.(Map); ok {
+		if m.value.Kind() == reflect.Map {
+			return setMapPath(m, path, value)
+		}
+
+		name, rest, _ := strings.Cut(path, ".")
+
+		if item, ok := m.items.lookup(name); ok {
+			return setPath(item.Value, rest, value)
+		}
+	}
+
+	return errors.New("no field found at " + path)
+}
+
+// expandGNUFlags rewrites args following the GNU conventions into the form
+// parsed by the flag package. Long flags start with two dashes, like --name or
+// --name=value, and arguments starting with a single dash are made of short
+// flags: -n value, -nvalue, or bundled flags like -vn value where all the flags
+// but the last one are booleans.
+//
+// Parsing stops at the first argument which is not a flag, or after "--".
+func expandGNUFlags(set *flag.FlagSet, args []string) []string {
+	expanded := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			return append(expanded, args[i:]...)
+		}
+
+		expanded = append(expanded, arg)
+
+		if arg[1] == '-' {
+			name, _, hasValue := strings.Cut(arg[2:], "=")
+
+			if f := set.Lookup(name); f != nil && !hasValue && !isBoolFlag(reflect.ValueOf(f.Value)) && i+1 < len(args) {
+				i++ // the next argument is the value of the flag
+				expanded = append(expanded, args[i])
+			}
+			continue
+		}
+
+		expanded = expanded[:len(expanded)-1]
+
+		for j, c := range arg[1:] {
+			short := string(c)
+			f := set.Lookup(short)
+
+			// Unknown flags are left to the flag package so it reports them,
+			// or returns flag.ErrHelp for -h.
+			if f == nil || isBoolFlag(reflect.ValueOf(f.Value)) {
+				expanded = append(expanded, "-"+short)
+				if f == nil {
+					break
+				}
+				continue
+			}
+
+			// The value of a short flag is the rest of the argument, or the
+			// next argument.
+			if value := strings.TrimPrefix(arg[1+j+len(short):], "="); len(value) != 0 {
+				expanded = append(expanded, "-"+short+"="+value)
+			} else if i+1 < len(args) {
+				i++
+				expanded = append(expanded, "-"+short+"="+args[i])
+			} else {
+				expanded = append(expanded, "-"+short)
+			}
+			break
+		}
+	}
+
+	return expanded
+}
+
+// expandMapFlags rewrites the arguments which set an entry of a map flag with a
+// dotted path, like -labels.team=core, into the key=value form accepted by the
+// flag, like -labels team=core.
+func expandMapFlags(set *flag.FlagSet, args []string) []string {
+	expanded := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// The flag package stops parsing at the first argument which is not a
+		// flag, or after "--".
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			return append(expanded, args[i:]...)
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+
+		if f, key := mapFlagEntry(set, name); len(key) != 0 {
+			switch {
+			case hasValue:
+			case f.value.Type().Elem().Kind() == reflect.Bool:
+				value = "true"
+			case i+1 < len(args):
+				i++
+				value = args[i]
+			default:
+				return append(expanded, args[i:]...)
+			}
+			expanded = append(expanded, "-"+name[:len(name)-len(key)-1], key+"="+value)
+			continue
+		}
+
+		expanded = append(expanded, arg)
+
+		if f := set.Lookup(name); f != nil && !hasValue && !isBoolFlag(reflect.ValueOf(f.Value)) && i+1 < len(args) {
+			i++ // the next argument is the value of the flag
+			expanded = append(expanded, args[i])
+		}
+	}
+
+	return expanded
+}
+
+// lookupField returns the flag of the configuration field of the given name.
+func lookupField(set *flag.FlagSet, name string) (*fieldFlag, bool) {
+	if f := set.Lookup(name); f != nil {
+		v, ok := f.Value.(*fieldFlag)
+		return v, ok
+	}
+	return nil, false
+}
+
+// mapFlagEntry returns the map flag that name starts with, and the path of the
+// entry that name refers to in the map.
+func mapFlagEntry(set *flag.FlagSet, name string) (mapFlag, string) {
+	for i := range name {
+		if name[i] == '.' {
+			if f, ok := lookupField(set, name[:i]); ok {
+				if m, ok := f.value.(mapFlag); ok {
+					return m, name[i+1:]
+				}
+			}
+		}
+	}
+	return mapFlag{}, ""
+}
@@ -0,0 +1,358 @@
+package conf
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type flagsConfig struct {
+	Tags    []string               `conf:"tags"`
+	Ports   []int                  `conf:"ports"`
+	Hosts   []string               `conf:"hosts" flag:"replace"`
+	Labels  map[string]string      `conf:"labels"`
+	Limits  map[int]int            `conf:"limits"`
+	Enabled map[string]bool        `conf:"enabled"`
+	Servers map[string]flagsServer `conf:"servers"`
+}
+
+type flagsServer struct {
+	Host string `conf:"host"`
+	Port int    `conf:"port"`
+}
+
+func TestFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  []string
+		cfg  func(*flagsConfig)
+	}{
+		{
+			name: "RepeatedSlice",
+			args: []string{"-tags", "a", "-tags", "b", "-ports=1", "-ports", "[2, 3]"},
+			cfg: func(c *flagsConfig) {
+				c.Tags, c.Ports = []string{"a", "b"}, []int{1, 2, 3}
+			},
+		},
+		{
+			name: "SliceOverridesSources",
+			args: []string{"-tags", "c"},
+			env:  []string{"TEST_TAGS=[a, b]"},
+			cfg:  func(c *flagsConfig) { c.Tags = []string{"c"} },
+		},
+		{
+			name: "ReplaceSlice",
+			args: []string{"-hosts", "[a, b]", "-hosts", "[c]"},
+			cfg:  func(c *flagsConfig) { c.Hosts = []string{"c"} },
+		},
+		{
+			name: "KeyValue",
+			args: []string{"-labels", "team=core", "-labels", "env=prod", "-limits", "8=1"},
+			env:  []string{"TEST_LABELS={team: web, zone: us}"},
+			cfg: func(c *flagsConfig) {
+				c.Labels = map[string]string{"team": "core", "env": "prod", "zone": "us"}
+				c.Limits = map[int]int{8: 1}
+			},
+		},
+		{
+			name: "DottedPath",
+			args: []string{"-labels.team=core", "-labels.a.b", "c", "-enabled.debug", "-servers.web.port", "80", "-servers.web.host=localhost"},
+			cfg: func(c *flagsConfig) {
+				c.Labels = map[string]string{"team": "core", "a.b": "c"}
+				c.Enabled = map[string]bool{"debug": true}
+				c.Servers = map[string]flagsServer{"web": {Host: "localhost", Port: 80}}
+			},
+		},
+		{
+			name: "YAMLObject",
+			args: []string{"-labels", "{team: core}", "-labels", "env=prod"},
+			cfg:  func(c *flagsConfig) { c.Labels = map[string]string{"team": "core", "env": "prod"} },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cfg, expected flagsConfig
+			expected.Labels = map[string]string{}
+			expected.Limits = map[int]int{}
+			expected.Enabled = map[string]bool{}
+			expected.Servers = map[string]flagsServer{}
+			test.cfg(&expected)
+
+			ld := Loader{
+				Name:    "test",
+				Args:    test.args,
+				Sources: []Source{NewEnvSource("test", test.env...)},
+			}
+
+			if _, _, err := ld.Load(&cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("bad configuration:\n<<< %+v\n>>> %+v", expected, cfg)
+			}
+		})
+	}
+}
+
+func TestFlagsErrors(t *testing.T) {
+	tests := []struct {
+		args  []string
+		error string
+	}{
+		{
+			args:  []string{"-labels", "team"},
+			error: `invalid value found in the -labels flag: expected key=value`,
+		},
+		{
+			args:  []string{"-limits.x=1"},
+			error: `invalid value found in the -limits flag: invalid map key "x": strconv.ParseInt: parsing "x": invalid syntax`,
+		},
+		{
+			args:  []string{"-servers.web.name=a"},
+			error: `invalid value found in the -servers flag: no field found at name`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args[0], func(t *testing.T) {
+			var cfg flagsConfig
+
+			_, _, err := (Loader{Name: "test", Args: test.args}).Load(&cfg)
+			if err == nil || err.Error() != test.error {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+}
+
+type gnuConfig struct {
+	Verbose bool     `conf:"verbose" short:"v" help:"Verbose output"`
+	Debug   bool     `conf:"debug" short:"d"`
+	Name    string   `conf:"name" short:"n" help:"Name of the program"`
+	Tags    []string `conf:"tags" short:"t"`
+	DB      struct {
+		Port int `conf:"port" short:"p"`
+	} `conf:"db"`
+}
+
+func TestGNUFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		gnu  bool
+		cfg  func(*gnuConfig)
+		rest []string
+	}{
+		{
+			name: "LongFlags",
+			args: []string{"--verbose", "--name=a", "--db.port", "80", "b"},
+			gnu:  true,
+			cfg:  func(c *gnuConfig) { c.Verbose, c.Name, c.DB.Port = true, "a", 80 },
+			rest: []string{"b"},
+		},
+		{
+			name: "ShortFlags",
+			args: []string{"-v", "-n", "a", "-p80", "-t", "x", "--tags", "y", "-t=z"},
+			gnu:  true,
+			cfg: func(c *gnuConfig) {
+				c.Verbose, c.Name, c.DB.Port, c.Tags = true, "a", 80, []string{"x", "y", "z"}
+			},
+		},
+		{
+			name: "BundledFlags",
+			args: []string{"-vdn", "a", "-dp", "80"},
+			gnu:  true,
+			cfg:  func(c *gnuConfig) { c.Verbose, c.Debug, c.Name, c.DB.Port = true, true, "a", 80 },
+		},
+		{
+			name: "Termination",
+			args: []string{"-v", "--", "-d", "--name=a"},
+			gnu:  true,
+			cfg:  func(c *gnuConfig) { c.Verbose = true },
+			rest: []string{"-d", "--name=a"},
+		},
+		{
+			name: "ShortFlagsWithoutGNU",
+			args: []string{"-v", "-n", "a", "-name", "b", "-t", "x", "-tags", "y"},
+			cfg:  func(c *gnuConfig) { c.Verbose, c.Name, c.Tags = true, "b", []string{"x", "y"} },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cfg, expected gnuConfig
+			test.cfg(&expected)
+
+			_, args, err := (Loader{Name: "test", Args: test.args, GNU: test.gnu}).Load(&cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("bad configuration: %+v", cfg)
+			}
+
+			if len(args) != 0 || len(test.rest) != 0 {
+				if !reflect.DeepEqual(args, test.rest) {
+					t.Errorf("bad arguments: %q", args)
+				}
+			}
+		})
+	}
+}
+
+func TestGNUFlagsErrors(t *testing.T) {
+	tests := []struct {
+		args  []string
+		error string
+	}{
+		{
+			args:  []string{"-verbose"},
+			error: "flag provided but not defined: -e",
+		},
+		{
+			args:  []string{"--verbos"},
+			error: "flag provided but not defined: --verbos, did you mean --verbose?",
+		},
+		{
+			args:  []string{"-vx"},
+			error: "flag provided but not defined: -x",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args[0], func(t *testing.T) {
+			var cfg gnuConfig
+
+			_, _, err := (Loader{Name: "test", Args: test.args, GNU: true}).Load(&cfg)
+			if err == nil || err.Error() != test.error {
+				t.Error("bad error:", err)
+			}
+		})
+	}
+
+	var cfg gnuConfig
+
+	if _, _, err := (Loader{Name: "test", Args: []string{"--help"}, GNU: true}).Load(&cfg); err != flag.ErrHelp {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestPrintHelpGNU(t *testing.T) {
+	var cfg gnuConfig
+	b := &bytes.Buffer{}
+
+	(Loader{Name: "test", GNU: true}).FprintHelp(b, &cfg)
+
+	const txt = "Usage:\n" +
+		"  test [-h] [--help] [options...]\n" +
+		"\n" +
+		"Options:\n" +
+		"  --db object\n" +
+		"\n" +
+		"  -p, --db.port int\n" +
+		"\n" +
+		"  -d, --debug\n" +
+		"\n" +
+		"  -n, --name string\n" +
+		"    \tName of the program\n" +
+		"\n" +
+		"  -t, --tags list\n" +
+		"\n" +
+		"  -v, --verbose\n" +
+		"    \tVerbose output\n" +
+		"\n"
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
+
+func TestShortFlagsOrigins(t *testing.T) {
+	var cfg gnuConfig
+
+	ld := Loader{
+		Name:    "test",
+		Args:    []string{"-v", "--name", "a", "-p", "80"},
+		Sources: []Source{NewEnvSource("test", "TEST_VERBOSE=false", "TEST_NAME=b", "TEST_DEBUG=true")},
+		GNU:     true,
+	}
+
+	_, _, origins, err := ld.LoadWithOrigins(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Origin{
+		"verbose": {Source: "flag", Key: "-v"},
+		"name":    {Source: "flag", Key: "--name"},
+		"db.port": {Source: "flag", Key: "-p"},
+		"debug":   {Source: "env", Key: "TEST_DEBUG"},
+		"tags":    {Source: "default"},
+	}
+
+	if !reflect.DeepEqual(origins, expected) {
+		t.Errorf("bad origins:\n<<< %#v\n>>> %#v", expected, origins)
+	}
+}
+
+func TestGNUFlagsMessages(t *testing.T) {
+	var cfg struct {
+		Host    string `conf:"host" required:"true"`
+		Name    string `conf:"name" deprecated:"it is unused"`
+		Verbose bool   `conf:"verbose" short:"v" deprecated:"it is always enabled"`
+	}
+	var warnings []string
+
+	ld := Loader{
+		Name:         "test",
+		Args:         []string{"--name", "a", "-v"},
+		GNU:          true,
+		OnDeprecated: func(d Deprecation) { warnings = append(warnings, d.String()) },
+	}
+
+	_, _, err := ld.Load(&cfg)
+	if err == nil || err.Error() != "missing required value for host, set it with the --host flag" {
+		t.Error("bad error:", err)
+	}
+
+	if !reflect.DeepEqual(warnings, []string{"the --name flag is deprecated: it is unused", "the -v flag is deprecated: it is always enabled"}) {
+		t.Errorf("bad warnings: %q", warnings)
+	}
+}
+
+func TestGNUFlagsCompletion(t *testing.T) {
+	var cfg gnuConfig
+	b := &bytes.Buffer{}
+
+	ld := Loader{Name: "test", GNU: true}
+
+	if err := ld.FprintCompletion(b, &cfg, "bash"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := "compgen -W '--db --db.port -p --debug -d --name -n --tags -t --verbose -v'"; !strings.Contains(b.String(), s) {
+		t.Errorf("%q not found in:\n%s", s, b.String())
+	}
+
+	b.Reset()
+
+	if err := ld.FprintCompletion(b, &cfg, "fish"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"-l 'verbose' -d 'Verbose output'\n",
+		"-s 'v' -d 'Verbose output'\n",
+		"-s 'p' -r\n",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("%q not found in:\n%s", s, b.String())
+		}
+	}
+}
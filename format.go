@@ -0,0 +1,117 @@
+package conf
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/segmentio/objconv/json"
+	"github.com/segmentio/objconv/yaml"
+)
+
+var fileFormats = struct {
+	sync.RWMutex
+	unmarshal map[string]func([]byte, interface{}) error
+}{
+	unmarshal: map[string]func([]byte, interface{}) error{
+		".yaml": yaml.Unmarshal,
+		".yml":  yaml.Unmarshal,
+		".json": json.Unmarshal,
+		".toml": UnmarshalTOML,
+		".env":  UnmarshalDotenv,
+	},
+}
+
+// RegisterFileFormat registers the function used to decode configuration files
+// with the extension ext (for example ".hcl") when the file source they're
+// loaded from detects their format.
+//
+// The function replaces any previous registration for the same extension,
+// extensions are case insensitive.
+func RegisterFileFormat(ext string, unmarshal func([]byte, interface{}) error) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	fileFormats.Lock()
+	fileFormats.unmarshal[strings.ToLower(ext)] = unmarshal
+	fileFormats.Unlock()
+}
+
+// detectFileFormat returns the function that should be used to decode the
+// configuration file at path, with content b.
+//
+// The format is determined by the file extension first, when it's not a known
+// extension the content of the file is inspected instead, falling back to YAML
+// if no other format could be recognized.
+func detectFileFormat(path string, b []byte) func([]byte, interface{}) error {
+	fileFormats.RLock()
+	defer fileFormats.RUnlock()
+
+	if f, ok := fileFormats.unmarshal[strings.ToLower(filepath.Ext(path))]; ok {
+		return f
+	}
+
+	return fileFormats.unmarshal[sniffFileFormat(b)]
+}
+
+var (
+	dotenvLine = regexp.MustCompile(`^(export\s+)?[A-Za-z_][A-Za-z0-9_]*=`)
+	tomlLine   = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z0-9_.-]+)\s*=`)
+)
+
+// sniffFileFormat guesses the format of a configuration file from the first
+// significant line of its content b, it returns the file extension associated
+// with the format.
+//
+// Lines like key="value" are valid in both .env and TOML files, so when the
+// first line looks like a variable the rest of the file is checked for TOML
+// syntax, like [table] headers or dotted keys, before choosing .env.
+func sniffFileFormat(b []byte) string {
+	if b = bytes.TrimSpace(b); len(b) != 0 && b[0] == '{' {
+		return ".json"
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+
+		switch {
+		case len(line) == 0, line[0] == '#', line == "---":
+			continue
+		case line[0] == '[':
+			return ".toml"
+		case dotenvLine.MatchString(line):
+			if hasTOMLSyntax(s) {
+				return ".toml"
+			}
+			return ".env"
+		case tomlLine.MatchString(line):
+			return ".toml"
+		}
+
+		break
+	}
+
+	return ".yaml"
+}
+
+// hasTOMLSyntax returns true if one of the remaining lines of s can only be
+// found in TOML files.
+func hasTOMLSyntax(s *bufio.Scanner) bool {
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+
+		switch {
+		case len(line) == 0, line[0] == '#':
+		case line[0] == '[':
+			return true
+		case !dotenvLine.MatchString(line) && tomlLine.MatchString(line):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDetectFileFormat(t *testing.T) {
+	type config struct {
+		Name string `conf:"name"`
+		DB   struct {
+			Host string `conf:"host"`
+		} `conf:"db"`
+	}
+
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"/tmp/conf-test-format.yaml", "name: test\ndb:\n  host: localhost\n"},
+		{"/tmp/conf-test-format.yml", "name: test\ndb:\n  host: localhost\n"},
+		{"/tmp/conf-test-format.json", `{"name":"test","db":{"host":"localhost"}}`},
+		{"/tmp/conf-test-format.toml", "name = \"test\"\n[db]\nhost = \"localhost\"\n"},
+		{"/tmp/conf-test-format.env", "# comment\nNAME=test\nexport DB_HOST=\"localhost\"\n"},
+		{"/tmp/conf-test-format-yaml", "---\nname: test\ndb: {host: localhost}\n"},
+		{"/tmp/conf-test-format-json", "\n  {\"name\": \"test\", \"db\": {\"host\": \"localhost\"}}"},
+		{"/tmp/conf-test-format-toml", "# comment\nname = 'test'\n[db]\nhost = 'localhost'\n"},
+		{"/tmp/conf-test-format-env", "NAME=test\nDB_HOST=localhost\n"},
+		{"/tmp/conf-test-format-toml-table", "name=\"test\"\n\n[db]\nhost=\"localhost\"\n"},
+		{"/tmp/conf-test-format-toml-dotted", "name=\"test\"\ndb.host = \"localhost\"\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			os.WriteFile(test.file, []byte(test.content), 0644)
+			defer os.Remove(test.file)
+
+			var cfg config
+
+			ld := Loader{
+				Name: "test",
+				Args: []string{"-config-file", test.file},
+				Sources: []Source{
+					NewFileSource("config-file", nil, os.ReadFile, nil),
+				},
+			}
+
+			if _, _, err := ld.Load(&cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.Name != "test" || cfg.DB.Host != "localhost" {
+				t.Errorf("bad configuration: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestRegisterFileFormat(t *testing.T) {
+	const configFile = "/tmp/conf-test-format.custom"
+	os.WriteFile(configFile, []byte("A: 1\n"), 0644)
+	defer os.Remove(configFile)
+
+	errCustom := errors.New("custom format")
+
+	RegisterFileFormat("CUSTOM", func([]byte, interface{}) error { return errCustom })
+	defer func() {
+		fileFormats.Lock()
+		delete(fileFormats.unmarshal, ".custom")
+		fileFormats.Unlock()
+	}()
+
+	var cfg struct{ A int }
+
+	ld := Loader{
+		Name: "test",
+		Args: []string{"-config-file", configFile},
+		Sources: []Source{
+			NewFileSource("config-file", nil, os.ReadFile, nil),
+		},
+	}
+
+	if _, _, err := ld.Load(&cfg); err != errCustom {
+		t.Error("bad error:", err)
+	}
+}
@@ -1,8 +1,9 @@
 module github.com/segmentio/conf
 
-go 1.18
+go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/segmentio/objconv v1.0.1
 	gopkg.in/go-playground/mold.v2 v2.2.0
 	gopkg.in/validator.v2 v2.0.1
@@ -1,9 +1,7 @@
 package conf
 
 import (
-	"bytes"
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -18,7 +16,6 @@ import (
 
 	// Load all default adapters of the objconv package.
 	_ "github.com/segmentio/objconv/adapters"
-	"github.com/segmentio/objconv/yaml"
 )
 
 var (
@@ -58,12 +55,27 @@ func Load(cfg interface{}) (args []string) {
 // pointer and no commands were set.
 func LoadWith(cfg interface{}, ld Loader) (cmd string, args []string) {
 	var err error
+
+	if ld.OnDeprecated == nil {
+		ld.OnDeprecated = printDeprecation
+	}
+
 	switch cmd, args, err = ld.Load(cfg); err {
 	case nil:
 	case flag.ErrHelp:
 		ld.PrintHelp(cfg)
 		os.Exit(0)
 	default:
+		switch e := err.(type) {
+		case *PrintConfigError:
+			if err = ld.printConfig(cfg, e); err == nil {
+				os.Exit(0)
+			}
+		case *CompletionError:
+			if err = ld.printCompletion(cfg, e.Shell); err == nil {
+				os.Exit(0)
+			}
+		}
 		ld.PrintHelp(cfg)
 		ld.PrintError(err)
 		os.Exit(1)
@@ -85,13 +97,49 @@ type Loader struct {
 	Args     []string  // list of arguments
 	Commands []Command // list of commands
 	Sources  []Source  // list of sources to load configuration from.
+
+	// Resolvers replace references to values stored elsewhere (for example
+	// file:///run/secrets/db) with the values they point to, indexed by URL
+	// scheme. See Resolver for details.
+	Resolvers map[string]Resolver
+
+	// When PrintConfig is true the loader accepts the -print-config and
+	// -explain-config flags, which cause Load to return a *PrintConfigError
+	// after loading the configuration.
+	PrintConfig bool
+
+	// OnDeprecated is called when a configuration field is set with a
+	// deprecated name, see the "deprecated" tag. When nil the deprecations are
+	// ignored, except by LoadWith and RunWith which print them as warnings to
+	// stderr.
+	OnDeprecated func(Deprecation)
+
+	// When Strict is true the values that don't match any configuration field
+	// are reported as errors instead of being ignored: unknown keys in
+	// configuration files, and environment variables starting with the prefix
+	// of an environment source. The errors suggest the closest valid key when
+	// one looks like a typo.
+	Strict bool
+
+	// When Completion is true the loader accepts a hidden -completion flag as
+	// first argument, which causes Load to return a *CompletionError with the
+	// name of the shell that the flag was set to.
+	Completion bool
+
+	// When GNU is true the arguments are parsed following the GNU conventions:
+	// long flags start with two dashes (--name=value or --name value), and
+	// arguments starting with a single dash are made of short flags, which are
+	// set with the "short" tag and may be bundled (-xvf file). Parsing stops
+	// at the first argument which is not a flag, or after "--".
+	GNU bool
 }
 
 // Load uses the loader ld to load the program configuration into cfg, and
 // returns the list of program arguments that were not used.
 //
 // The function returns flag.ErrHelp when the list of arguments contained -h,
-// -help, or --help.
+// -help, or --help, and a *PrintConfigError when it contained -print-config or
+// -explain-config and the loader has PrintConfig set.
 //
 // The cfg argument is expected to be a pointer to a struct type where exported
 // fields or fields with a "conf" tag will be used to load the program
@@ -99,29 +147,44 @@ type Loader struct {
 // The function panics if cfg is not a pointer to struct, or if it's a nil
 // pointer and no commands were set.
 func (ld Loader) Load(cfg interface{}) (cmd string, args []string, err error) {
+	return ld.loadConfig(cfg, nil)
+}
+
+// LoadWithOrigins behaves like Load but also returns the origin of the value
+// of each configuration field, indexed by the dotted path of the field (for
+// example "db.host").
+//
+// Fields that were not set by any source are reported with a "default" origin.
+// A source setting a field to the value it already had is not detected, the
+// field keeps the origin it had before.
+func (ld Loader) LoadWithOrigins(cfg interface{}) (cmd string, args []string, origins map[string]Origin, err error) {
+	origins = make(map[string]Origin)
+	cmd, args, err = ld.loadConfig(cfg, origins)
+	return
+}
+
+func (ld Loader) loadConfig(cfg interface{}, origins map[string]Origin) (cmd string, args []string, err error) {
 	var v reflect.Value
+	var pc *printConfigFlags
 
 	if cfg == nil {
-		v = reflect.ValueOf(&struct{}{})
+		v = reflect.ValueOf(&struct{}{}).Elem()
 	} else {
-		v = reflect.ValueOf(cfg)
+		v = configValue(cfg)
 	}
 
-	if v.Kind() != reflect.Ptr {
-		panic(fmt.Sprintf("cannot load configuration into non-pointer type: %T", cfg))
+	names := make([]string, len(ld.Commands))
+	for i, c := range ld.Commands {
+		names[i] = c.Name
 	}
 
-	if v.IsNil() {
-		panic(fmt.Sprintf("cannot load configuration into nil pointer of type: %T", cfg))
-	}
-
-	if v = v.Elem(); v.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("cannot load configuration into non-struct pointer: %T", cfg))
+	if err = ld.completionRequest(completionCommand(names)); err != nil {
+		return
 	}
 
 	if len(ld.Commands) != 0 {
 		if len(ld.Args) == 0 {
-			err = errors.New("missing command")
+			err = ErrMissingCommand
 			return
 		}
 
@@ -134,7 +197,7 @@ func (ld Loader) Load(cfg interface{}) (cmd string, args []string, err error) {
 		}
 
 		if !found {
-			err = errors.New("unknown command: " + ld.Args[0])
+			err = &UnknownCommandError{Name: ld.Args[0], Suggestion: suggest(ld.Args[0], names)}
 			return
 		}
 
@@ -144,28 +207,134 @@ func (ld Loader) Load(cfg interface{}) (cmd string, args []string, err error) {
 		}
 	}
 
-	if args, err = ld.load(v); err != nil {
+	if ld.PrintConfig {
+		pc = &printConfigFlags{}
+
+		if origins == nil {
+			origins = make(map[string]Origin)
+		}
+	}
+
+	var errs errorList
+
+	if args, errs, err = ld.load(makeNodeStruct(v, v.Type()), origins, pc); err != nil {
 		return
 	}
 
-	if err = Modifier.Struct(context.Background(), cfg); err != nil {
+	if err = errs.append(validateConfig(v)).err(); err != nil {
+		return
+	}
+
+	err = pc.request(origins)
+	return
+}
+
+// configValue returns the struct value that cfg points to, the function panics
+// if cfg is not a non-nil pointer to a struct.
+func configValue(cfg interface{}) reflect.Value {
+	v := reflect.ValueOf(cfg)
+
+	if v.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("cannot load configuration into non-pointer type: %T", cfg))
+	}
+
+	if v.IsNil() {
+		panic(fmt.Sprintf("cannot load configuration into nil pointer of type: %T", cfg))
+	}
+
+	if v = v.Elem(); v.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("cannot load configuration into non-struct pointer: %T", cfg))
+	}
+
+	return v
+}
+
+// validateConfig applies the modifiers and validation rules to the loaded
+// configuration struct v.
+func validateConfig(v reflect.Value) (err error) {
+	if err = Modifier.Struct(context.Background(), v.Addr().Interface()); err != nil {
 		return
 	}
 
 	if err = validator.Validate(v.Interface()); err != nil {
-		err = makeValidationError(err, v.Type())
+		err = makeValidationError(err, v)
 	}
 
 	return
 }
 
-func (ld Loader) load(cfg reflect.Value) (args []string, err error) {
-	node := makeNodeStruct(cfg, cfg.Type())
+// checkRequired returns an error for each field of node marked as required
+// which was not set by any of the sources, telling users how it can be set.
+// Fields are set if their path is in loaded, or if they have a value since not
+// all sources report the fields they set.
+func (ld Loader) checkRequired(node Map, loaded map[string]bool) error {
+	var errs errorList
+	sources, file := ld.keySources()
+
+	node.Scan(func(path []string, item MapItem) {
+		path = append(path[:len(path):len(path)], item.Name)
+		key := strings.Join(path, ".")
+
+		if !item.Required || loaded[key] || !isZeroValue(reflect.ValueOf(item.Value.Value())) {
+			return
+		}
+
+		ways := []string{"the " + flagName(key, ld.GNU) + " flag"}
+
+		for _, k := range sourceKeys(sources, path) {
+			ways = append(ways, keyDescription(k))
+		}
+
+		if file != nil {
+			ways = append(ways, "the "+key+" key of the configuration file")
+		}
+
+		errs = append(errs, fmt.Errorf("missing required value for %s, set it with %s", key, joinOr(ways)))
+	})
+
+	return errs.err()
+}
+
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return !v.IsValid() || v.IsZero()
+}
+
+// joinOr joins the list of alternatives in s, for example "a, b, or c".
+func joinOr(s []string) string {
+	switch len(s) {
+	case 1:
+		return s[0]
+	case 2:
+		return s[0] + " or " + s[1]
+	default:
+		return strings.Join(s[:len(s)-1], ", ") + ", or " + s[len(s)-1]
+	}
+}
+
+// load loads the configuration into node from the program arguments and the
+// sources of ld.
+//
+// Errors that prevent the arguments from being parsed are returned as err and
+// abort the loading. Other errors are collected in errs so all the problems of
+// the configuration can be reported at once.
+func (ld Loader) load(node Map, origins map[string]Origin, pc *printConfigFlags) (args []string, errs errorList, err error) {
 	set := newFlagSet(node, ld.Name, ld.Sources...)
 
+	if pc != nil {
+		pc.register(set)
+	}
+
+	if origins != nil {
+		trackOrigins(node, origins)
+	}
+
 	// Parse the arguments a first time so the sources that implement the
 	// FlagSource interface get their values loaded.
-	if err = set.Parse(ld.Args); err != nil {
+	if err = parseFlags(set, ld.Args, ld.GNU); err != nil {
 		return
 	}
 
@@ -173,22 +342,88 @@ func (ld Loader) load(cfg reflect.Value) (args []string, err error) {
 	// loader.
 	// Order is important here because the values will get overwritten by each
 	// source that loads the configuration.
+	//
+	// The fields that the sources set are recorded, so required fields which
+	// were explicitly set to their zero value are not reported as missing.
+	loaded := make(map[string]bool)
+
+	node.hooks = &decodeHooks{
+		deprecated: ld.deprecated,
+		loaded:     func(path []string) { loaded[flagPath(node, strings.Join(path, "."))] = true },
+	}
+
 	for _, source := range ld.Sources {
-		if err = source.Load(node); err != nil {
-			return
+		var before map[string]snapshotItem
+
+		if origins != nil {
+			before = snapshot(node)
+		}
+
+		if s, ok := source.(strictSource); ok && ld.Strict {
+			errs = errs.append(s.loadStrict(node))
+		} else {
+			errs = errs.append(source.Load(node))
+		}
+
+		if origins != nil {
+			updateOrigins(node, before, origins, func(path []string) Origin {
+				return originOf(source, path)
+			})
 		}
 	}
 
 	// Parse the arguments a second time to overwrite values loaded by sources
 	// which were also passed to the program arguments.
-	if err = set.Parse(ld.Args); err != nil {
+	if err = parseFlags(set, ld.Args, ld.GNU); err != nil {
 		return
 	}
 
+	var after map[string]snapshotItem
+	if origins != nil {
+		after = snapshot(node)
+	}
+
+	shorts := make(map[string]string)
+	scanShorts(node, nil, func(path []string, item MapItem) {
+		shorts[item.Short] = strings.Join(path, ".")
+	})
+
+	set.Visit(func(f *flag.Flag) {
+		if _, ok := f.Value.(*fieldFlag); ok {
+			path, ok := shorts[f.Name]
+			if !ok {
+				path = flagPath(node, f.Name)
+			}
+			loaded[path] = true
+
+			if origins != nil {
+				updateFlagOrigins(after, path, flagName(f.Name, ld.GNU), origins)
+			}
+		}
+	})
+
+	deprecated := deprecatedFlags(node)
+	set.Visit(func(f *flag.Flag) {
+		if msg, ok := deprecated[f.Name]; ok {
+			ld.deprecated(Deprecation{Key: Origin{Source: "flag", Key: flagName(f.Name, ld.GNU)}, Message: msg})
+		}
+	})
+
+	// References are resolved last so they can be set by any source.
+	errs = errs.append(ld.resolve(context.Background(), node))
+	errs = errs.append(ld.checkRequired(node, loaded))
+
 	args = set.Args()
 	return
 }
 
+// resetter is implemented by flag values which accumulate the arguments of
+// their flag, like flag sources, they are reset before the arguments get parsed
+// so values are not accumulated again each time the loader parses them.
+type resetter interface {
+	reset()
+}
+
 var DefaultLoader Loader
 
 func init() {
@@ -203,9 +438,15 @@ func defaultLoader(args []string, env []string) Loader {
 		Name: name,
 		Args: args[1:],
 		Sources: []Source{
-			NewFileSource("config-file", makeEnvVars(env), os.ReadFile, yaml.Unmarshal),
+			NewFileSource("config-file", makeEnvVars(env), os.ReadFile, nil),
 			NewEnvSource(name, env...),
 		},
+		// Only explicit references are resolved by default, so values which
+		// happen to be file:// or env:// URLs are left untouched.
+		Resolvers: map[string]Resolver{
+			"ref+file": NewFileResolver(os.ReadFile),
+			"ref+env":  NewEnvResolver(env...),
+		},
 	}
 }
 
@@ -228,7 +469,7 @@ func makeEnvVars(env []string) (vars map[string]string) {
 	return vars
 }
 
-func makeValidationError(err error, typ reflect.Type) error {
+func makeValidationError(err error, v reflect.Value) error {
 	if errmap, ok := err.(validator.ErrorMap); ok {
 		errkeys := make([]string, 0, len(errmap))
 		errlist := make(errorList, 0, len(errmap))
@@ -240,22 +481,22 @@ func makeValidationError(err error, typ reflect.Type) error {
 		sort.Strings(errkeys)
 
 		for _, errkey := range errkeys {
-			path := fieldPath(typ, errkey)
-
-			if len(errmap[errkey]) == 1 {
-				errlist = append(errlist, fmt.Errorf("invalid value passed to %s: %s", path, errmap[errkey][0]))
-			} else {
-				buf := &bytes.Buffer{}
-				fmt.Fprintf(buf, "invalid value passed to %s: ", path)
-
-				for i, errval := range errmap[errkey] {
-					if i != 0 {
-						buf.WriteString("; ")
-					}
-					buf.WriteString(errval.Error())
+			path := fieldPath(v.Type(), errkey)
+			errs := errmap[errkey]
+			rules := failedRules(validateTag(v.Type(), errkey), errs)
+
+			for i, errval := range errs {
+				// Custom validators may include the invalid value in their
+				// error messages, make sure secrets don't leak this way.
+				if value, secret := secretValue(v, errkey); secret {
+					errval = redactError(errval, value)
 				}
 
-				errlist = append(errlist, errors.New(buf.String()))
+				errlist = append(errlist, &ValidationError{
+					Path: path,
+					Rule: rules[i],
+					Err:  errval,
+				})
 			}
 		}
 
@@ -264,13 +505,78 @@ func makeValidationError(err error, typ reflect.Type) error {
 	return err
 }
 
+// errorList is the error type returned when loading a configuration failed
+// with multiple errors.
 type errorList []error
 
+// Error returns the messages of all errors in the list, one per line.
 func (err errorList) Error() string {
-	if len(err) > 0 {
-		return err[0].Error()
+	msgs := make([]string, len(err))
+	for i, e := range err {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors in the list, so errors.Is and errors.As look for
+// matches in all of them.
+func (err errorList) Unwrap() []error {
+	return err
+}
+
+// append adds err to the list, the errors of err are added one by one if it is
+// also a list. Nil errors are ignored.
+func (err errorList) append(e error) errorList {
+	switch x := e.(type) {
+	case nil:
+		return err
+	case errorList:
+		return append(err, x...)
+	default:
+		return append(err, e)
+	}
+}
+
+// err returns nil if the list is empty, the only error of the list if it has a
+// single one, or the list itself.
+func (err errorList) err() error {
+	switch len(err) {
+	case 0:
+		return nil
+	case 1:
+		return err[0]
+	default:
+		return err
 	}
-	return ""
+}
+
+// validateTag returns the validate tag of the field at path in typ, where path
+// is a field path reported by the validator package.
+func validateTag(typ reflect.Type, path string) string {
+	var field reflect.StructField
+
+	for _, name := range strings.Split(path, ".") {
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			name = name[:i]
+		}
+
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+
+		if typ.Kind() != reflect.Struct {
+			return ""
+		}
+
+		f, ok := typ.FieldByName(name)
+		if !ok {
+			return ""
+		}
+
+		field, typ = f, f.Type
+	}
+
+	return field.Tag.Get("validate")
 }
 
 func fieldPath(typ reflect.Type, path string) string {
@@ -1,12 +1,15 @@
 package conf
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"net/mail"
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -166,6 +169,13 @@ var (
 			env:  []string{"TEST_S=Hello World!"},
 		},
 
+		{
+			val:  struct{ S Secret }{"Hello World!"},
+			file: `S: Hello World!`,
+			args: []string{"-S", "Hello World!"},
+			env:  []string{"TEST_S=Hello World!"},
+		},
+
 		{
 			val:  struct{ L []int }{[]int{1, 2, 3}},
 			file: `L: [1, 2, 3]`,
@@ -572,3 +582,167 @@ func TestEmbeddedStruct(t *testing.T) {
 		t.Errorf("bad value:\n<<< %#v\n>>> %#v", testVal, v)
 	}
 }
+
+func TestRequired(t *testing.T) {
+	type config struct {
+		Name string `conf:"name" required:"true"`
+		DB   struct {
+			Host string `conf:"host" required:"true"`
+			Port int    `conf:"port" required:"true"`
+		} `conf:"db"`
+		Tags []string `conf:"tags" required:"true"`
+	}
+
+	t.Run("Set", func(t *testing.T) {
+		var cfg config
+
+		ld := Loader{
+			Name:    "myapp",
+			Args:    []string{"-name", "test", "-tags", "[a]"},
+			Sources: []Source{NewEnvSource("myapp", "MYAPP_DB_HOST=localhost", "MYAPP_DB_PORT=5432")},
+		}
+
+		if _, _, err := ld.Load(&cfg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		var cfg config
+
+		ld := Loader{
+			Name: "myapp",
+			Args: []string{"-db.port", "5432"},
+			Sources: []Source{
+				NewFileSource("config-file", nil, os.ReadFile, nil),
+				NewEnvSource("myapp"),
+			},
+		}
+
+		_, _, err := ld.Load(&cfg)
+
+		errs, ok := err.(errorList)
+		if !ok {
+			t.Fatal("bad error:", err)
+		}
+
+		expected := []string{
+			"missing required value for name, set it with the -name flag, the MYAPP_NAME environment variable, or the name key of the configuration file",
+			"missing required value for db.host, set it with the -db.host flag, the MYAPP_DB_HOST environment variable, or the db.host key of the configuration file",
+			"missing required value for tags, set it with the -tags flag, the MYAPP_TAGS environment variable, or the tags key of the configuration file",
+		}
+
+		if len(errs) != len(expected) {
+			t.Fatal("bad errors:", errs)
+		}
+
+		for i, e := range errs {
+			if e.Error() != expected[i] {
+				t.Errorf("bad error #%d: %s", i, e)
+			}
+		}
+	})
+
+	t.Run("ZeroValues", func(t *testing.T) {
+		const path = "/tmp/conf-test-required.yaml"
+		os.WriteFile(path, []byte("count: 0\n"), 0644)
+		defer os.Remove(path)
+
+		var cfg struct {
+			Port    int    `conf:"port" required:"true"`
+			Verbose bool   `conf:"verbose" required:"true"`
+			Name    string `conf:"name" required:"true"`
+			Count   int    `conf:"count" required:"true"`
+		}
+
+		ld := Loader{
+			Name: "myapp",
+			Args: []string{"-port", "0", "-verbose=false", "-config-file", path},
+			Sources: []Source{
+				NewFileSource("config-file", nil, os.ReadFile, nil),
+				NewEnvSource("myapp", "MYAPP_NAME="),
+			},
+		}
+
+		if _, _, err := ld.Load(&cfg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("FlagOnly", func(t *testing.T) {
+		var cfg struct {
+			Name string `conf:"name" required:"true"`
+		}
+
+		_, _, err := (Loader{Name: "myapp"}).Load(&cfg)
+
+		if err == nil || err.Error() != "missing required value for name, set it with the -name flag" {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("Help", func(t *testing.T) {
+		var cfg config
+		b := &bytes.Buffer{}
+		(Loader{Name: "myapp"}).FprintHelp(b, &cfg)
+
+		if !strings.Contains(b.String(), "  -db.host string\n    \t(required)\n") {
+			t.Error(b.String())
+		}
+	})
+}
+
+type errorsTestError struct{}
+
+func (errorsTestError) Error() string { return "test error" }
+
+func TestLoadErrors(t *testing.T) {
+	var cfg struct {
+		Host  string        `conf:"host" validate:"nonzero"`
+		Port  int           `conf:"port"`
+		Delay time.Duration `conf:"delay"`
+	}
+
+	ld := Loader{
+		Name: "myapp",
+		Sources: []Source{
+			NewEnvSource("myapp", "MYAPP_PORT=abc", "MYAPP_DELAY=1 minute"),
+			SourceFunc(func(Map) error { return errorsTestError{} }),
+		},
+	}
+
+	_, _, err := ld.Load(&cfg)
+
+	errs, ok := err.(errorList)
+	if !ok {
+		t.Fatal("bad error:", err)
+	}
+
+	if len(errs) != 4 {
+		t.Fatal("bad errors:", errs)
+	}
+
+	for i, prefix := range []string{
+		"invalid value found in the MYAPP_PORT environment variable: ",
+		"invalid value found in the MYAPP_DELAY environment variable: ",
+		"test error",
+		"invalid value passed to host: zero value",
+	} {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("bad error #%d: %s", i, errs[i])
+		}
+	}
+
+	if s := err.Error(); strings.Count(s, "\n") != 3 {
+		t.Error("bad error message:", s)
+	}
+
+	if !errors.Is(err, errorsTestError{}) {
+		t.Error("errors.Is did not find the source error")
+	}
+
+	var target errorsTestError
+	if !errors.As(err, &target) {
+		t.Error("errors.As did not find the source error")
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/segmentio/objconv"
 	"github.com/segmentio/objconv/json"
@@ -188,6 +191,14 @@ func makeNodeStruct(v reflect.Value, t reflect.Type) (m Map) {
 		}
 		props[item.Name] = struct{}{}
 	}
+	for _, item := range m.Items() {
+		for _, alias := range item.Aliases {
+			if _, ok := props[alias]; ok {
+				panic("alias '" + alias + "' of '" + item.Name + "' conflicts with another name in configuration: " + t.String())
+			}
+			props[alias] = struct{}{}
+		}
+	}
 
 	return
 }
@@ -221,32 +232,162 @@ func populateNodeStruct(originalT reflect.Type, path string, v reflect.Value, t
 		}
 
 		m.items.push(MapItem{
-			Name:  name,
-			Help:  help,
-			Value: makeNode(fv),
+			Name:       name,
+			Help:       help,
+			Value:      makeNode(fv),
+			Secret:     isSecretField(ft),
+			Required:   ft.Tag.Get("required") == "true",
+			Validate:   ft.Tag.Get("validate"),
+			Aliases:    fieldAliases(ft),
+			Deprecated: ft.Tag.Get("deprecated"),
+			Replace:    ft.Tag.Get("flag") == "replace",
+			Short:      fieldShort(originalT, ft),
 		})
 	}
 }
 
+// fieldAliases returns the list of names found in the "alias" tag of f.
+func fieldAliases(f reflect.StructField) (aliases []string) {
+	for _, alias := range strings.Split(f.Tag.Get("alias"), ",") {
+		if alias = strings.TrimSpace(alias); len(alias) != 0 {
+			aliases = append(aliases, alias)
+		}
+	}
+	return
+}
+
+// fieldShort returns the short flag name found in the "short" tag of f, the
+// function panics if it is not a single character.
+func fieldShort(t reflect.Type, f reflect.StructField) string {
+	short := f.Tag.Get("short")
+	if len(short) != 0 && (utf8.RuneCountInString(short) != 1 || short == "-" || short == "=") {
+		panic("invalid short flag name '" + short + "' found on field " + f.Name + " in configuration: " + t.String())
+	}
+	return short
+}
+
 func makeNodeMap(v reflect.Value, t reflect.Type) (m Map) {
 	if v.IsNil() && v.CanSet() {
 		v.Set(reflect.MakeMap(v.Type()))
 	}
 
+	if !isMapKeyType(t.Key()) {
+		panic("unsupported map key type found in configuration: " + t.String())
+	}
+
 	m.value = v
 	m.items = newMapItems()
 
-	for _, key := range v.MapKeys() {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return lessMapKey(keys[i], keys[j]) })
+
+	for _, key := range keys {
 		m.items.push(MapItem{
-			Name:  key.String(), // only string keys are supported for now
+			Name:  mapKeyString(key),
 			Value: makeNode(v.MapIndex(key)),
 		})
 	}
 
-	sort.Sort(m.items)
 	return
 }
 
+// isMapKeyType returns true if t can be used as the key type of maps in a
+// configuration: strings, integers, and types implementing
+// encoding.TextUnmarshaler.
+func isMapKeyType(t reflect.Type) bool {
+	if isTextMapKey(t) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTextMapKey returns true if map keys of type t are parsed with their
+// UnmarshalText method, or formatted with their MarshalText method.
+func isTextMapKey(t reflect.Type) bool {
+	return t.Implements(textMarshalerInterface) || reflect.PtrTo(t).Implements(textUnmarshalerInterface)
+}
+
+// mapKeyString returns the name of the map key k, which is used as key in the
+// serialized configurations and as part of the flag and variable names. Keys
+// which have no MarshalText method and are neither strings nor integers are
+// formatted with the fmt package.
+func mapKeyString(k reflect.Value) string {
+	if k.Type().Implements(textMarshalerInterface) {
+		if b, err := k.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	case reflect.String:
+		return k.String()
+	default:
+		return fmt.Sprint(k.Interface())
+	}
+}
+
+// parseMapKey parses s into a key of type t, which must be a valid map key
+// type as reported by isMapKeyType.
+func parseMapKey(s string, t reflect.Type) (reflect.Value, error) {
+	k := reflect.New(t)
+
+	if u, ok := k.Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return k.Elem(), fmt.Errorf("invalid map key %q: %w", s, err)
+		}
+		return k.Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return k.Elem(), fmt.Errorf("invalid map key %q: %w", s, err)
+		}
+		k.Elem().SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return k.Elem(), fmt.Errorf("invalid map key %q: %w", s, err)
+		}
+		k.Elem().SetUint(u)
+
+	default:
+		k.Elem().SetString(s)
+	}
+
+	return k.Elem(), nil
+}
+
+// lessMapKey orders map keys, integers are sorted by value and other keys by
+// their names.
+func lessMapKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !isTextMapKey(a.Type()) {
+			return a.Int() < b.Int()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !isTextMapKey(a.Type()) {
+			return a.Uint() < b.Uint()
+		}
+	}
+	return mapKeyString(a) < mapKeyString(b)
+}
+
 func makeNodeSlice(v reflect.Value, t reflect.Type) (a Array) {
 	n := v.Len()
 	a.value = v
@@ -298,6 +439,9 @@ func (s Scalar) Value() interface{} {
 }
 
 func (s Scalar) String() string {
+	if s.isSecret() {
+		return redacted
+	}
 	b, _ := yaml.Marshal(s)
 	return string(bytes.TrimSpace(b))
 }
@@ -327,6 +471,9 @@ func (s Scalar) Set(str string) (err error) {
 }
 
 func (s Scalar) EncodeValue(e objconv.Encoder) error {
+	if s.isSecret() {
+		return e.Encode(redacted)
+	}
 	return e.Encode(s.Value())
 }
 
@@ -338,10 +485,15 @@ func (s Scalar) IsBoolFlag() bool {
 	return s.value.IsValid() && s.value.Kind() == reflect.Bool
 }
 
+func (s Scalar) isSecret() bool {
+	return s.value.IsValid() && s.value.Type() == secretType
+}
+
 // Array is a node type that wraps a slice value.
 type Array struct {
 	value reflect.Value
 	items *arrayItems
+	hooks *decodeHooks
 }
 
 func (a Array) Kind() NodeKind {
@@ -409,7 +561,9 @@ func (a Array) EncodeValue(e objconv.Encoder) (err error) {
 func (a Array) DecodeValue(d objconv.Decoder) (err error) {
 	a.pop(a.Len())
 	return d.DecodeArray(func(d objconv.Decoder) (err error) {
-		if err = a.push().DecodeValue(d); err != nil {
+		i := strconv.Itoa(a.Len())
+
+		if err = withHooks(a.push(), i, a.hooks).DecodeValue(d); err != nil {
 			a.pop(1)
 		}
 		return
@@ -418,11 +572,30 @@ func (a Array) DecodeValue(d objconv.Decoder) (err error) {
 
 func (a Array) push() Node {
 	i := a.Len()
+	p := a.value.Pointer()
 	a.value.Set(reflect.Append(a.value, reflect.Zero(a.value.Type().Elem())))
+
+	// Appending may have moved the elements to a new backing array, the nodes
+	// are rebuilt so they don't reference the old one.
+	if a.value.Pointer() != p {
+		a.items.pop(i)
+		for j := 0; j != i; j++ {
+			a.items.push(makeNode(a.value.Index(j)))
+		}
+	}
+
 	a.items.push(makeNode(a.value.Index(i)))
 	return a.items.index(i)
 }
 
+// append adds the elements of the slice v at the end of a.
+func (a Array) append(v reflect.Value) {
+	for i, n := 0, v.Len(); i != n; i++ {
+		a.push()
+		a.value.Index(a.Len() - 1).Set(v.Index(i))
+	}
+}
+
 func (a Array) pop(n int) {
 	if n != 0 {
 		a.value.Set(a.value.Slice(0, a.Len()-n))
@@ -434,13 +607,104 @@ func (a Array) pop(n int) {
 type Map struct {
 	value reflect.Value
 	items *mapItems
+	hooks *decodeHooks
+}
+
+// decodeHooks are functions called when decoding values into nodes, to report
+// keys that the loader or the sources may want to warn about. Paths are made of
+// the keys leading to the reported one.
+type decodeHooks struct {
+	prefix []string
+
+	// unknown is called with keys which don't match any field of a struct,
+	// known lists the names of the fields.
+	unknown func(path []string, known []string)
+
+	// deprecated is called when a deprecated key is used. The key of the
+	// deprecation is set to the path, sources fill in where it was found.
+	deprecated func(d Deprecation)
+
+	// loaded is called with the path of the fields which get a value from a
+	// source, even when it is equal to the value they already had.
+	loaded func(path []string)
+}
+
+func (h *decodeHooks) path(key string) []string {
+	return append(h.prefix[:len(h.prefix):len(h.prefix)], key)
+}
+
+// withHooks returns a copy of node which reports the keys found while decoding
+// it to h, prefixed with key.
+func withHooks(node Node, key string, h *decodeHooks) Node {
+	if h == nil {
+		return node
+	}
+
+	sub := &decodeHooks{
+		prefix:     h.path(key),
+		unknown:    h.unknown,
+		deprecated: h.deprecated,
+		loaded:     h.loaded,
+	}
+
+	switch n := node.(type) {
+	case Map:
+		n.hooks = sub
+		return n
+	case Array:
+		n.hooks = sub
+		return n
+	default:
+		return node
+	}
+}
+
+// deprecated reports the use of a deprecated key to the hooks of m.
+func (m Map) deprecated(key Origin, msg string) {
+	if m.hooks != nil && m.hooks.deprecated != nil {
+		m.hooks.deprecated(Deprecation{Key: key, Message: msg})
+	}
+}
+
+// loaded reports to the hooks of m that the field at path was set by a source.
+func (m Map) loaded(path []string) {
+	if m.hooks != nil && m.hooks.loaded != nil {
+		m.hooks.loaded(path)
+	}
 }
 
 // MapItem is the type of elements stored in a Map.
 type MapItem struct {
-	Name  string
-	Help  string
-	Value Node
+	Name     string
+	Help     string
+	Value    Node
+	Secret   bool   // redact the value when printing it
+	Required bool   // the value must be set by one of the sources
+	Validate string // validation rules of the field, from its "validate" tag
+
+	// Aliases are other names that the field may be set with, from its "alias"
+	// tag.
+	Aliases []string
+
+	// Deprecated is the message of the field's "deprecated" tag, which is shown
+	// when the field is set by one of its aliases, or by any name if it has no
+	// aliases.
+	Deprecated string
+
+	// Replace is set by the "flag:\"replace\"" tag, the flag of the field then
+	// replaces the whole value instead of appending to slices or setting entries
+	// of maps.
+	Replace bool
+
+	// Short is the single character name of the field's flag, from its "short"
+	// tag, like "v" for -v.
+	Short string
+}
+
+// isDeprecated returns true if setting the item with name must be reported as
+// the use of a deprecated name.
+func (item MapItem) isDeprecated(name string) bool {
+	return len(item.Deprecated) != 0 && (len(item.Aliases) == 0 || name != item.Name)
 }
 
 func (m Map) Kind() NodeKind {
@@ -487,7 +751,11 @@ func (m Map) String() string {
 		if i != 0 {
 			b.WriteString(", ")
 		}
-		fmt.Fprintf(b, "%s: %s", item.Name, item.Value)
+		if item.Secret {
+			fmt.Fprintf(b, "%s: %s", item.Name, redacted)
+		} else {
+			fmt.Fprintf(b, "%s: %s", item.Name, item.Value)
+		}
 
 		if len(item.Help) != 0 {
 			fmt.Fprintf(b, " (%s)", item.Help)
@@ -509,7 +777,12 @@ func (m Map) EncodeValue(e objconv.Encoder) error {
 		if err = ke.Encode(item.Name); err != nil {
 			return
 		}
-		if err = item.Value.EncodeValue(ve); err != nil {
+		if item.Secret {
+			err = ve.Encode(redacted)
+		} else {
+			err = item.Value.EncodeValue(ve)
+		}
+		if err != nil {
 			return
 		}
 		i++
@@ -526,39 +799,129 @@ func (m Map) DecodeValue(d objconv.Decoder) error {
 		}
 
 		if m.value.Kind() == reflect.Struct {
-			if item := m.Item(key); item != nil {
-				return item.DecodeValue(vd)
+			if item, ok := m.items.lookup(key); ok {
+				if item.isDeprecated(key) && m.hooks != nil {
+					m.deprecated(Origin{Key: strings.Join(m.hooks.path(key), ".")}, item.Deprecated)
+				}
+				if err = withHooks(item.Value, key, m.hooks).DecodeValue(vd); err == nil && m.hooks != nil {
+					m.loaded(m.hooks.path(key))
+				}
+				return
+			}
+			if m.hooks != nil && m.hooks.unknown != nil {
+				m.hooks.unknown(m.hooks.path(key), m.names())
 			}
 			return vd.Decode(nil) // discard
 		}
 
-		name := reflect.ValueOf(key)
-		node := makeNode(reflect.New(m.value.Type().Elem()))
-
-		if err = node.DecodeValue(vd); err != nil {
+		name, err := parseMapKey(key, m.value.Type().Key())
+		if err != nil {
 			return
 		}
 
-		m.value.SetMapIndex(name, reflect.ValueOf(node.Value()))
-		m.items.put(MapItem{
-			Name:  key,
-			Value: makeNode(m.value.MapIndex(name)),
+		// Decode over the existing entry so values loaded from multiple
+		// sources get merged instead of replaced.
+		return m.setEntry(name, func(node Node) error {
+			return withHooks(node, mapKeyString(name), m.hooks).DecodeValue(vd)
 		})
-		return
 	})
 }
 
+// setEntry calls set with a copy of the entry of m at key, which is zero if the
+// map has no such entry, then stores it back in the map if set succeeded. Keys
+// are normalized so entries set with different representations of the same key
+// (like "08" and "8") get merged.
+func (m Map) setEntry(key reflect.Value, set func(Node) error) error {
+	elem := reflect.New(m.value.Type().Elem()).Elem()
+
+	if prev := m.value.MapIndex(key); prev.IsValid() {
+		elem.Set(prev)
+	}
+
+	if err := set(makeNode(elem)); err != nil {
+		return err
+	}
+
+	m.value.SetMapIndex(key, elem)
+	m.items.put(MapItem{
+		Name:  mapKeyString(key),
+		Value: makeNode(m.value.MapIndex(key)),
+	})
+	return nil
+}
+
+func (m Map) names() []string {
+	items := m.Items()
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+// Scan calls do with the path and the item of every field of m and of the
+// fields nested in them. Items nested in secret fields are passed as secrets as
+// well.
 func (m Map) Scan(do func([]string, MapItem)) {
-	m.scan(make([]string, 0, 10), do)
+	m.scan(make([]string, 0, 10), false, do)
 }
 
-func (m Map) scan(path []string, do func([]string, MapItem)) {
+// scanNames calls do with every path that the items of m can be set with,
+// including paths made of aliases, and the item found at the end of the path.
+// Paths made of aliases are passed first, so sources setting the values in this
+// order give precedence to the primary names of the items.
+//
+// The deprecation message is set when one of the names in the path must be
+// reported as deprecated. Like with Scan, items nested in secret fields are
+// passed as secrets.
+func (m Map) scanNames(do func(path []string, item MapItem, alias bool, deprecated string)) {
+	var primary []func()
+
+	m.scanAllNames(make([]string, 0, 10), false, "", false, func(path []string, item MapItem, alias bool, deprecated string) {
+		if alias {
+			do(path, item, alias, deprecated)
+		} else {
+			path = append([]string{}, path...)
+			primary = append(primary, func() { do(path, item, alias, deprecated) })
+		}
+	})
+
+	for _, f := range primary {
+		f()
+	}
+}
+
+func (m Map) scanAllNames(path []string, alias bool, deprecated string, secret bool, do func([]string, MapItem, bool, string)) {
 	for _, item := range m.Items() {
+		item.Secret = item.Secret || secret
+
+		for i, name := range append([]string{item.Name}, item.Aliases...) {
+			p := append(path, name)
+			a := alias || i != 0
+			d := deprecated
+
+			if item.isDeprecated(name) {
+				d = item.Deprecated
+			}
+
+			do(p, item, a, d)
+
+			if v, ok := item.Value.(Map); ok {
+				v.scanAllNames(p, a, d, item.Secret, do)
+			}
+		}
+	}
+}
+
+func (m Map) scan(path []string, secret bool, do func([]string, MapItem)) {
+	for _, item := range m.Items() {
+		item.Secret = item.Secret || secret
+
 		do(path, item)
 
 		switch v := item.Value.(type) {
 		case Map:
-			v.scan(append(path, item.Name), do)
+			v.scan(append(path, item.Name), item.Secret, do)
 		}
 	}
 }
@@ -615,6 +978,21 @@ func (m *mapItems) index(name string) int {
 	return -1
 }
 
+// lookup returns the item with name, or the item that name is an alias of.
+func (m *mapItems) lookup(name string) (MapItem, bool) {
+	if i := m.index(name); i >= 0 {
+		return m.nodes[i], true
+	}
+	for _, item := range m.nodes {
+		for _, alias := range item.Aliases {
+			if alias == name {
+				return item, true
+			}
+		}
+	}
+	return MapItem{}, false
+}
+
 func (m *mapItems) len() int {
 	return len(m.nodes)
 }
@@ -652,5 +1030,6 @@ var (
 	timeDurationType = reflect.TypeOf(time.Duration(0))
 
 	objconvValueDecoderInterface = reflect.TypeOf((*objconv.ValueDecoder)(nil)).Elem()
+	textMarshalerInterface       = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 	textUnmarshalerInterface     = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 )
@@ -562,3 +562,121 @@ func Test_InvalidFlattenedEmbeddedStructs(t *testing.T) {
 		})
 	}
 }
+
+type mapKeyRegion string
+
+type mapKeyVersion struct{ major, minor int }
+
+func (v mapKeyVersion) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("v%d.%d", v.major, v.minor)), nil
+}
+
+func (v *mapKeyVersion) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "v%d.%d", &v.major, &v.minor)
+	return err
+}
+
+// mapKeyZone has no MarshalText method, its keys are normalized when parsed.
+type mapKeyZone string
+
+func (z *mapKeyZone) UnmarshalText(b []byte) error {
+	*z = mapKeyZone(strings.ToUpper(string(b)))
+	return nil
+}
+
+// mapKeyPoint has no MarshalText method and is not a string or an integer, its
+// keys are formatted with the fmt package.
+type mapKeyPoint struct{ x, y int }
+
+func (p *mapKeyPoint) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d:%d", &p.x, &p.y)
+	return err
+}
+
+func TestMapKeys(t *testing.T) {
+	type port struct {
+		Host string `conf:"host"`
+	}
+
+	type config struct {
+		Ports     map[int]port             `conf:"ports"`
+		Endpoints map[mapKeyRegion]string  `conf:"endpoints"`
+		Releases  map[mapKeyVersion]string `conf:"releases"`
+		Weights   map[uint8]float64        `conf:"weights"`
+		Zones     map[mapKeyZone]int       `conf:"zones"`
+		Points    map[mapKeyPoint]string   `conf:"points"`
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		cfg := config{Ports: map[int]port{8080: {Host: "localhost"}}}
+
+		ld := Loader{
+			Name: "test",
+			Args: []string{"-endpoints", "{us-east-1: a, eu-west-1: b}"},
+			Sources: []Source{NewEnvSource("test",
+				"TEST_PORTS={443: {host: secure}, 80: {host: web}}",
+				"TEST_RELEASES={v1.2: stable}",
+				"TEST_WEIGHTS={1: 0.5}",
+				"TEST_ZONES={us-east: 1}",
+				"TEST_POINTS={\"1:2\": a}",
+			)},
+		}
+
+		if _, _, err := ld.Load(&cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := config{
+			Ports:     map[int]port{80: {Host: "web"}, 443: {Host: "secure"}, 8080: {Host: "localhost"}},
+			Endpoints: map[mapKeyRegion]string{"us-east-1": "a", "eu-west-1": "b"},
+			Releases:  map[mapKeyVersion]string{{1, 2}: "stable"},
+			Weights:   map[uint8]float64{1: 0.5},
+			Zones:     map[mapKeyZone]int{"US-EAST": 1},
+			Points:    map[mapKeyPoint]string{{1, 2}: "a"},
+		}
+
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("bad configuration: %+v", cfg)
+		}
+	})
+
+	t.Run("String", func(t *testing.T) {
+		cfg := config{
+			Ports:    map[int]port{80: {Host: "a"}, 443: {Host: "b"}, 8080: {Host: "c"}},
+			Releases: map[mapKeyVersion]string{{1, 2}: "stable"},
+		}
+
+		if s := makeNode(reflect.ValueOf(&cfg.Ports)).String(); s != "{ 80: { host: a }, 443: { host: b }, 8080: { host: c } }" {
+			t.Error("bad string:", s)
+		}
+
+		if s := makeNode(reflect.ValueOf(&cfg.Releases)).String(); s != "{ v1.2: stable }" {
+			t.Error("bad string:", s)
+		}
+
+		points := map[mapKeyPoint]string{{1, 2}: "a"}
+
+		if s := makeNode(reflect.ValueOf(&points)).String(); s != "{ {1 2}: a }" {
+			t.Error("bad string:", s)
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		var cfg config
+
+		_, _, err := (Loader{Name: "test", Args: []string{"-weights", "{256: 1}"}}).Load(&cfg)
+
+		if err == nil || !strings.Contains(err.Error(), `invalid map key "256"`) {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("UnsupportedKey", func(t *testing.T) {
+		defer func() {
+			if msg, _ := recover().(string); !strings.Contains(msg, "unsupported map key type") {
+				t.Error("bad panic:", msg)
+			}
+		}()
+		makeNode(reflect.ValueOf(&map[float64]int{}))
+	})
+}
@@ -0,0 +1,144 @@
+package conf
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/segmentio/objconv/yaml"
+)
+
+// Origin describes where the value of a configuration field was loaded from.
+type Origin struct {
+	Source string // kind of source, e.g. "default", "flag", "env", "file" or "configmap"
+	Key    string // key the value was found at, e.g. a flag or variable name
+	File   string // path of the file the value was read from, if any
+}
+
+// String returns a human-readable representation of o.
+func (o Origin) String() string {
+	s := o.Source
+
+	switch {
+	case len(o.File) != 0 && len(o.Key) != 0:
+		s += " " + o.File + " (" + o.Key + ")"
+	case len(o.File) != 0:
+		s += " " + o.File
+	case len(o.Key) != 0:
+		s += " " + o.Key
+	}
+
+	return s
+}
+
+// OriginSource is an optional interface that sources may implement to report
+// where the values they loaded came from.
+//
+// After a source was loaded the loader detects which configuration fields it
+// changed, and calls Origin with the path of each of those fields. Sources that
+// don't implement this interface are reported with a generic "source" origin.
+type OriginSource interface {
+	Source
+
+	// Origin returns the origin of the value that the last call to Load set on
+	// the configuration field at path.
+	Origin(path []string) Origin
+}
+
+var (
+	defaultOrigin = Origin{Source: "default"}
+	sourceOrigin  = Origin{Source: "source"}
+)
+
+// originOf returns the origin of the value set by source on the field at path.
+func originOf(source Source, path []string) Origin {
+	if s, ok := source.(OriginSource); ok {
+		return s.Origin(path)
+	}
+	return sourceOrigin
+}
+
+// snapshotItem is a copy of the value of a configuration field at the time a
+// snapshot was taken.
+type snapshotItem struct {
+	path  []string
+	value string
+}
+
+// snapshot captures the values of all fields of m, indexed by their dotted
+// path.
+func snapshot(m Map) map[string]snapshotItem {
+	s := make(map[string]snapshotItem)
+
+	m.Scan(func(path []string, item MapItem) {
+		if isStructMap(item.Value) {
+			return
+		}
+		p := make([]string, 0, len(path)+1)
+		p = append(p, path...)
+		p = append(p, item.Name)
+		// the raw value is encoded instead of calling String so secrets are
+		// compared as well
+		b, _ := yaml.Marshal(item.Value.Value())
+		s[strings.Join(p, ".")] = snapshotItem{path: p, value: string(b)}
+	})
+
+	return s
+}
+
+// trackOrigins sets the origin of every field of m in origins to default.
+func trackOrigins(m Map, origins map[string]Origin) {
+	for key := range snapshot(m) {
+		origins[key] = defaultOrigin
+	}
+}
+
+// updateOrigins records in origins the fields that have changed between the
+// before snapshot and the current state of m, using origin to determine where
+// their new values came from.
+func updateOrigins(m Map, before map[string]snapshotItem, origins map[string]Origin, origin func([]string) Origin) {
+	for key, item := range snapshot(m) {
+		if prev, ok := before[key]; !ok || prev.value != item.value {
+			origins[key] = origin(item.path)
+		}
+	}
+}
+
+// updateFlagOrigins records in origins the fields of the snapshot s that were
+// set by the flag passed as flag in the program arguments, including all fields
+// nested under it. The path is the one of the field that the flag sets, which
+// differs from the name of the flag when it is an alias or a short flag.
+func updateFlagOrigins(s map[string]snapshotItem, path string, flag string, origins map[string]Origin) {
+	for key := range s {
+		if key == path || strings.HasPrefix(key, path+".") {
+			origins[key] = Origin{Source: "flag", Key: flag}
+		}
+	}
+}
+
+// flagPath returns the path of the field of m that the dotted flag name refers
+// to, with the aliases in name replaced by the names of the fields.
+func flagPath(m Map, name string) string {
+	parts := strings.Split(name, ".")
+	node := Node(m)
+
+	for i, part := range parts {
+		v, ok := node.(Map)
+		if !ok {
+			break
+		}
+
+		item, ok := v.items.lookup(part)
+		if !ok {
+			break
+		}
+
+		parts[i], node = item.Name, item.Value
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func isStructMap(node Node) bool {
+	m, ok := node.(Map)
+	return ok && m.value.IsValid() && m.value.Kind() == reflect.Struct
+}
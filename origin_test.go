@@ -0,0 +1,77 @@
+package conf
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/objconv/yaml"
+)
+
+func TestLoadWithOrigins(t *testing.T) {
+	const configFile = "/tmp/conf-origins-test.yml"
+	os.WriteFile(configFile, []byte(`---
+file: 1
+env: 1
+flag: 1
+db:
+  host: localhost
+`), 0644)
+	defer os.Remove(configFile)
+
+	var cfg struct {
+		Default int `conf:"default"`
+		File    int `conf:"file"`
+		Env     int `conf:"env"`
+		Flag    int `conf:"flag"`
+		Custom  int `conf:"custom"`
+		DB      struct {
+			Host string `conf:"host"`
+			Port int    `conf:"port"`
+		} `conf:"db"`
+	}
+
+	ld := defaultLoader([]string{"test", "-config-file", configFile, "-flag", "3"}, []string{"TEST_ENV=2", "TEST_FLAG=2"})
+	ld.Sources = append(ld.Sources, SourceFunc(func(dst Map) error {
+		return yaml.Unmarshal([]byte(`custom: 4`), dst)
+	}))
+
+	_, _, origins, err := ld.LoadWithOrigins(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Origin{
+		"default": {Source: "default"},
+		"file":    {Source: "file", Key: "file", File: configFile},
+		"env":     {Source: "env", Key: "TEST_ENV"},
+		"flag":    {Source: "flag", Key: "-flag"},
+		"custom":  {Source: "source"},
+		"db.host": {Source: "file", Key: "db.host", File: configFile},
+		"db.port": {Source: "default"},
+	}
+
+	if !reflect.DeepEqual(origins, expected) {
+		t.Errorf("bad origins:\n<<< %#v\n>>> %#v", expected, origins)
+	}
+}
+
+func TestOriginString(t *testing.T) {
+	tests := []struct {
+		origin Origin
+		string string
+	}{
+		{Origin{Source: "default"}, "default"},
+		{Origin{Source: "flag", Key: "-db.host"}, "flag -db.host"},
+		{Origin{Source: "env", Key: "TEST_DB_HOST"}, "env TEST_DB_HOST"},
+		{Origin{Source: "file", Key: "db.host", File: "config.yml"}, "file config.yml (db.host)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.string, func(t *testing.T) {
+			if s := test.origin.String(); s != test.string {
+				t.Error(s)
+			}
+		})
+	}
+}
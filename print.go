@@ -55,25 +55,8 @@ func (ld Loader) fprintError(w io.Writer, err error, col colors) {
 }
 
 func (ld Loader) fprintHelp(w io.Writer, cfg interface{}, col colors) {
-	var m Map
-
-	if cfg != nil {
-		v := reflect.ValueOf(cfg)
-		if v.Kind() == reflect.Ptr {
-			v = v.Elem()
-		}
-		m = makeNodeStruct(v, v.Type())
-	}
-
 	fmt.Fprintf(w, "%s\n", col.titles("Usage:"))
-	switch {
-	case len(ld.Usage) != 0:
-		fmt.Fprintf(w, "  %s %s\n\n", ld.Name, ld.Usage)
-	case len(ld.Commands) != 0:
-		fmt.Fprintf(w, "  %s [command] [options...]\n\n", ld.Name)
-	default:
-		fmt.Fprintf(w, "  %s [-h] [-help] [options...]\n\n", ld.Name)
-	}
+	fmt.Fprintf(w, "  %s\n\n", ld.usage())
 
 	if len(ld.Commands) != 0 {
 		fmt.Fprintf(w, "%s\n", col.titles("Commands:"))
@@ -94,7 +77,31 @@ func (ld Loader) fprintHelp(w io.Writer, cfg interface{}, col colors) {
 		fmt.Fprintln(w)
 	}
 
-	set := newFlagSet(m, ld.Name, ld.Sources...)
+	ld.fprintOptions(w, makeConfigNode(cfg), col)
+}
+
+// usage returns the usage line of the program.
+func (ld Loader) usage() string {
+	switch {
+	case len(ld.Usage) != 0:
+		return ld.Name + " " + ld.Usage
+	case len(ld.Commands) != 0:
+		return ld.Name + " [command] [options...]"
+	default:
+		return ld.Name + " [-h] [" + flagName("help", ld.GNU) + "] [options...]"
+	}
+}
+
+// optionName returns the flags setting o as shown in help messages and
+// reference documentation, like "-v, --verbose".
+func (ld Loader) optionName(o option) string {
+	if len(o.short) != 0 {
+		return "-" + o.short + ", " + flagName(o.name, ld.GNU)
+	}
+	return flagName(o.name, ld.GNU)
+}
+
+func (ld Loader) fprintOptions(w io.Writer, m Map, col colors) {
 	if m.Len() != 0 {
 		fmt.Fprintf(w, "%s\n", col.titles("Options:"))
 	}
@@ -102,18 +109,161 @@ func (ld Loader) fprintHelp(w io.Writer, cfg interface{}, col colors) {
 	// Outputs the flags following the same format than the standard flag
 	// package. The main difference is in the type names which are set to
 	// values returned by prettyType.
-	set.VisitAll(func(f *flag.Flag) {
-		var t string
+	for _, o := range ld.options(m) {
 		var h []string
+		name := ld.optionName(o)
+
+		// put help message inline for short boolean flags
+		inline := o.boolean && len(name) < 5
+
+		fmt.Fprintf(w, "  %s", col.keys(name))
+
+		switch {
+		case !o.boolean:
+			fmt.Fprintf(w, " %s\n", col.types(o.typ))
+		case !inline:
+			fmt.Fprint(w, "\n")
+		}
+
+		if s := o.help; len(s) != 0 {
+			h = append(h, s)
+		}
+
+		if s := o.defval; len(s) != 0 {
+			h = append(h, col.defvals("(default "+s+")"))
+		}
+
+		if o.required {
+			h = append(h, col.defvals("(required)"))
+		}
+
+		switch {
+		case len(o.aliases) != 0 && len(o.deprecated) != 0:
+			h = append(h, col.defvals("(deprecated alias "+strings.Join(o.aliases, ", ")+": "+o.deprecated+")"))
+		case len(o.aliases) != 0:
+			h = append(h, col.defvals("(alias "+strings.Join(o.aliases, ", ")+")"))
+		case len(o.deprecated) != 0:
+			h = append(h, col.defvals("(deprecated: "+o.deprecated+")"))
+		}
+
+		for _, k := range o.keys {
+			h = append(h, col.defvals("("+k.Source+" "+k.Key+")"))
+		}
+
+		if len(h) != 0 {
+			if !inline {
+				fmt.Fprint(w, "    ")
+			}
+			fmt.Fprintf(w, "\t%s\n", strings.Join(h, " "))
+		}
+
+		fmt.Fprint(w, "\n")
+	}
+}
+
+// option describes a program option, it carries the information displayed in
+// help messages and reference documentation.
+type option struct {
+	name     string   // name of the flag, without the leading dash
+	short    string   // short name of the flag, without the leading dash
+	typ      string   // type of the option, as returned by prettyType
+	help     string   // help message
+	defval   string   // default value, empty if it should not be displayed
+	boolean  bool     // the flag takes no value
+	required bool     // the option must be set
+	keys     []Origin // keys setting the option in the sources which have them
+	key      string   // key setting the option in configuration files
+
+	aliases    []string // other flags setting the option, with the leading dash
+	deprecated string   // deprecation message of the option, or of its aliases
+}
+
+// keySources returns the sources of ld which implement the KeySource interface,
+// and its configuration file source if it has one. They are used to tell users
+// where the options can be set.
+func (ld Loader) keySources() (keys []KeySource, file *fileSource) {
+	for _, source := range ld.Sources {
+		switch s := source.(type) {
+		case KeySource:
+			keys = append(keys, s)
+		case *fileSource:
+			file = s
+		}
+	}
+	return
+}
+
+// sourceKeys returns the keys of the configuration field at path in the key
+// sources.
+func sourceKeys(sources []KeySource, path []string) (keys []Origin) {
+	for _, source := range sources {
+		if k := source.Key(path); len(k.Key) != 0 {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// options returns the list of options of a program loading its configuration
+// into m, sorted by name.
+func (ld Loader) options(m Map) (opts []option) {
+	secrets := secretPaths(m)
+	required := make(map[string]bool)
+	set := newFlagSet(m, ld.Name, ld.Sources...)
+	if ld.PrintConfig {
+		(&printConfigFlags{}).register(set)
+	}
+
+	aliases := make(map[string]bool)
+	items := make(map[string]MapItem)
+	shorts := make(map[string]bool)
+
+	scanShorts(m, nil, func(path []string, item MapItem) {
+		shorts[item.Short] = true
+	})
+
+	m.Scan(func(path []string, item MapItem) {
+		if item.Required {
+			required[strings.Join(append(path, item.Name), ".")] = true
+		}
+		items[strings.Join(append(path, item.Name), ".")] = item
+	})
+
+	m.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+		if alias {
+			aliases[strings.Join(path, ".")] = true
+		}
+	})
+
+	sources, file := ld.keySources()
+
+	set.VisitAll(func(f *flag.Flag) {
 		var empty bool
-		var boolean bool
 		var object bool
 		var list bool
 
-		switch v := f.Value.(type) {
-		case Node:
+		// Aliases and short flags are listed in the help of the option they
+		// are an alias of.
+		if aliases[f.Name] || shorts[f.Name] {
+			return
+		}
+
+		o := option{name: f.Name, help: f.Usage, required: required[f.Name]}
+
+		if item, ok := items[f.Name]; ok {
+			prefix := f.Name[:strings.LastIndexByte(f.Name, '.')+1]
+			for _, alias := range item.Aliases {
+				o.aliases = append(o.aliases, flagName(prefix+alias, ld.GNU))
+			}
+			o.deprecated = item.Deprecated
+			o.short = item.Short
+		}
+
+		switch fv := f.Value.(type) {
+		case *fieldFlag:
+			v := fv.node()
 			x := reflect.ValueOf(v.Value())
-			t = prettyType(x.Type())
+			o.typ = prettyType(x.Type())
 			empty = isEmptyValue(x)
 
 			switch v.(type) {
@@ -122,41 +272,33 @@ func (ld Loader) fprintHelp(w io.Writer, cfg interface{}, col colors) {
 			case Array:
 				list = true
 			default:
-				boolean = isBoolFlag(x)
+				o.boolean = isBoolFlag(x)
 			}
 
-		case FlagSource:
-			t = "source"
-		default:
-			t = "value"
-		}
-
-		fmt.Fprintf(w, "  %s", col.keys("-"+f.Name))
+			o.keys = sourceKeys(sources, strings.Split(f.Name, "."))
 
-		switch {
-		case !boolean:
-			fmt.Fprintf(w, " %s\n", col.types(t))
-		case len(f.Name) >= 4: // put help message inline for boolean flags
-			fmt.Fprint(w, "\n")
-		}
-
-		if s := f.Usage; len(s) != 0 {
-			h = append(h, s)
-		}
+			if file != nil {
+				o.key = f.Name
+			}
 
-		if s := f.DefValue; len(s) != 0 && !empty && !(boolean || object || list) {
-			h = append(h, col.defvals("(default "+s+")"))
+		case FlagSource:
+			o.typ = "source"
+		default:
+			o.typ = "value"
+			o.boolean = isBoolFlag(reflect.ValueOf(f.Value))
 		}
 
-		if len(h) != 0 {
-			if !boolean || len(f.Name) >= 4 {
-				fmt.Fprint(w, "    ")
+		if s := f.DefValue; len(s) != 0 && !empty && !(o.boolean || object || list) {
+			if secrets[f.Name] {
+				s = redacted
 			}
-			fmt.Fprintf(w, "\t%s\n", strings.Join(h, " "))
+			o.defval = s
 		}
 
-		fmt.Fprint(w, "\n")
+		opts = append(opts, o)
 	})
+
+	return
 }
 
 func prettyType(t reflect.Type) string {
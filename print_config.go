@@ -0,0 +1,171 @@
+package conf
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/json"
+	"github.com/segmentio/objconv/yaml"
+)
+
+// PrintConfigError is returned by Loader.Load when the -print-config or
+// -explain-config flags were passed to a loader with PrintConfig set.
+//
+// When this error is returned the configuration has been fully loaded, modified
+// and validated. Programs would usually print it with FprintConfig or
+// FprintOrigins and exit, which is what Load and LoadWith do.
+type PrintConfigError struct {
+	Format  string            // "yaml" or "json", set when -print-config was passed
+	Explain bool              // true when -explain-config was passed
+	Origins map[string]Origin // origins of the configuration values
+}
+
+// Error satisfies the error interface.
+func (e *PrintConfigError) Error() string {
+	return "printing of the configuration was requested"
+}
+
+// FprintConfig writes the configuration in cfg to w, format must be "yaml" or
+// "json".
+func (ld Loader) FprintConfig(w io.Writer, cfg interface{}, format string) error {
+	var e *objconv.Encoder
+
+	switch format {
+	case "yaml":
+		e = yaml.NewEncoder(w)
+	case "json":
+		e = json.NewPrettyEncoder(w)
+	default:
+		return errors.New("unsupported configuration format: " + format)
+	}
+
+	if err := e.Encode(makeConfigNode(cfg)); err != nil {
+		return err
+	}
+
+	if format == "json" {
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+
+	return nil
+}
+
+// FprintOrigins writes to w the value of each field of the configuration in cfg
+// along with the origin of the value, as returned by LoadWithOrigins.
+func (ld Loader) FprintOrigins(w io.Writer, cfg interface{}, origins map[string]Origin) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tORIGIN")
+
+	m := makeConfigNode(cfg)
+	secrets := secretPaths(m)
+
+	m.Scan(func(path []string, item MapItem) {
+		if isStructMap(item.Value) {
+			return
+		}
+
+		key := strings.Join(append(path, item.Name), ".")
+		origin, ok := origins[key]
+		if !ok {
+			origin = defaultOrigin
+		}
+
+		value := item.Value.String()
+		if secrets[key] {
+			value = redacted
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", key, value, origin)
+	})
+
+	return tw.Flush()
+}
+
+func (ld Loader) printConfig(cfg interface{}, p *PrintConfigError) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := ld.fprintConfig(w, cfg, p); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (ld Loader) fprintConfig(w io.Writer, cfg interface{}, p *PrintConfigError) error {
+	if p.Explain {
+		return ld.FprintOrigins(w, cfg, p.Origins)
+	}
+	return ld.FprintConfig(w, cfg, p.Format)
+}
+
+func makeConfigNode(cfg interface{}) (m Map) {
+	if node, ok := cfg.(Map); ok {
+		return node
+	}
+	if cfg != nil {
+		v := reflect.ValueOf(cfg)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		m = makeNodeStruct(v, v.Type())
+	}
+	return
+}
+
+// printConfigFlags holds the values of the -print-config and -explain-config
+// flags.
+type printConfigFlags struct {
+	format  printFormatFlag
+	explain bool
+}
+
+func (p *printConfigFlags) register(set *flag.FlagSet) {
+	set.Var(&p.format, "print-config", "Print the configuration as yaml (or json with -print-config=json) and exit.")
+	set.BoolVar(&p.explain, "explain-config", false, "Print the origin of each configuration value and exit.")
+}
+
+// request returns a *PrintConfigError if p is not nil and printing of the
+// configuration was requested, otherwise it returns nil.
+func (p *printConfigFlags) request(origins map[string]Origin) error {
+	if p == nil || (len(p.format) == 0 && !p.explain) {
+		return nil
+	}
+	return &PrintConfigError{
+		Format:  string(p.format),
+		Explain: p.explain,
+		Origins: origins,
+	}
+}
+
+// printFormatFlag is a boolean-like flag which also accepts the name of the
+// format that the configuration should be printed in.
+type printFormatFlag string
+
+func (f *printFormatFlag) Set(s string) error {
+	switch s {
+	case "true", "yaml":
+		*f = "yaml"
+	case "json":
+		*f = "json"
+	case "false":
+		*f = ""
+	default:
+		return errors.New("unsupported configuration format: " + s)
+	}
+	return nil
+}
+
+func (f *printFormatFlag) String() string {
+	return string(*f)
+}
+
+func (f *printFormatFlag) IsBoolFlag() bool {
+	return true
+}
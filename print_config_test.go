@@ -0,0 +1,117 @@
+package conf
+
+import (
+	"bytes"
+	"testing"
+)
+
+type printConfigTest struct {
+	Name string `conf:"name"`
+	DB   struct {
+		Host string `conf:"host"`
+		Port int    `conf:"port"`
+	} `conf:"db"`
+}
+
+func TestPrintConfig(t *testing.T) {
+	tests := []struct {
+		args   []string
+		format string
+		output string
+	}{
+		{
+			args:   []string{"-print-config"},
+			format: "yaml",
+			output: "name: test\ndb:\n  host: localhost\n  port: 5432\n",
+		},
+		{
+			args:   []string{"-print-config=yaml"},
+			format: "yaml",
+			output: "name: test\ndb:\n  host: localhost\n  port: 5432\n",
+		},
+		{
+			args:   []string{"-print-config=json"},
+			format: "json",
+			output: "{\n  \"name\": \"test\",\n  \"db\": {\n    \"host\": \"localhost\",\n    \"port\": 5432\n  }\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			cfg := printConfigTest{Name: "test"}
+			cfg.DB.Port = 5432
+
+			ld := Loader{
+				Name:        "test",
+				Args:        append(test.args, "-db.host", "localhost"),
+				PrintConfig: true,
+			}
+
+			_, _, err := ld.Load(&cfg)
+
+			p, ok := err.(*PrintConfigError)
+			if !ok {
+				t.Fatal("bad error:", err)
+			}
+			if p.Format != test.format || p.Explain {
+				t.Errorf("bad print request: %#v", p)
+			}
+
+			b := &bytes.Buffer{}
+			if err := ld.fprintConfig(b, &cfg, p); err != nil {
+				t.Fatal(err)
+			}
+			if s := b.String(); s != test.output {
+				t.Errorf("bad output:\n%s", s)
+			}
+		})
+	}
+}
+
+func TestExplainConfig(t *testing.T) {
+	cfg := printConfigTest{Name: "test"}
+
+	ld := Loader{
+		Name:        "test",
+		Args:        []string{"-explain-config", "-db.host", "localhost"},
+		Sources:     []Source{NewEnvSource("test", "TEST_DB_PORT=5432")},
+		PrintConfig: true,
+	}
+
+	_, _, err := ld.Load(&cfg)
+
+	p, ok := err.(*PrintConfigError)
+	if !ok {
+		t.Fatal("bad error:", err)
+	}
+	if !p.Explain {
+		t.Errorf("bad print request: %#v", p)
+	}
+
+	b := &bytes.Buffer{}
+	if err := ld.fprintConfig(b, &cfg, p); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = "KEY      VALUE      ORIGIN\n" +
+		"name     test       default\n" +
+		"db.host  localhost  flag -db.host\n" +
+		"db.port  5432       env TEST_DB_PORT\n"
+
+	if s := b.String(); s != txt {
+		t.Errorf("bad output:\n%s", s)
+	}
+}
+
+func TestPrintConfigDisabled(t *testing.T) {
+	var cfg printConfigTest
+
+	ld := Loader{
+		Name: "test",
+		Args: []string{"-print-config"},
+	}
+
+	if _, _, err := ld.Load(&cfg); err == nil || err.Error() != "flag provided but not defined: -print-config" {
+		t.Error("bad error:", err)
+	}
+}
@@ -118,3 +118,55 @@ func TestPrintHelp(t *testing.T) {
 		t.Error(len(s), len(txt))
 	}
 }
+
+type vaultSource struct{}
+
+func (vaultSource) Load(dst Map) error { return nil }
+
+func (vaultSource) Key(path []string) Origin {
+	if len(path) == 1 && path[0] == "password" {
+		return Origin{Source: "vault", Key: "secret/password"}
+	}
+	return Origin{}
+}
+
+func TestPrintHelpKeys(t *testing.T) {
+	var cfg struct {
+		Password Secret `conf:"password" help:"Password of the user" required:"true"`
+		DB       struct {
+			MaxConns int `conf:"max-conns" help:"Maximum number of connections"`
+		} `conf:"db"`
+	}
+
+	ld := Loader{
+		Name:    "myapp",
+		Sources: []Source{NewEnvSource("myapp"), vaultSource{}},
+	}
+
+	b := &bytes.Buffer{}
+	ld.FprintHelp(b, &cfg)
+
+	const txt = "Usage:\n" +
+		"  myapp [-h] [-help] [options...]\n" +
+		"\n" +
+		"Options:\n" +
+		"  -db object\n" +
+		"    \t(env MYAPP_DB)\n" +
+		"\n" +
+		"  -db.max-conns int\n" +
+		"    \tMaximum number of connections (env MYAPP_DB_MAX_CONNS)\n" +
+		"\n" +
+		"  -password secret\n" +
+		"    \tPassword of the user (required) (env MYAPP_PASSWORD) (vault secret/password)\n" +
+		"\n"
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+
+	_, _, err := ld.Load(&cfg)
+
+	if err == nil || err.Error() != "missing required value for password, set it with the -password flag, the MYAPP_PASSWORD environment variable, or the secret/password key of the vault source" {
+		t.Error("bad error:", err)
+	}
+}
@@ -0,0 +1,302 @@
+package conf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FprintManPage writes to w the man page of a program loading its
+// configuration into cfg with ld, in the roff format.
+//
+// The page documents the commands and options of the program, with their types,
+// help messages and default values, and the names of the environment variables
+// and configuration file keys that set them.
+func (ld Loader) FprintManPage(w io.Writer, cfg interface{}) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, ".TH %s 1\n", roffQuote(strings.ToUpper(ld.Name)))
+	fmt.Fprintf(b, ".SH NAME\n%s\n", roffEscape(ld.Name))
+	fmt.Fprintf(b, ".SH SYNOPSIS\n%s\n", roffEscape(ld.usage()))
+
+	if len(ld.Commands) != 0 {
+		fmt.Fprintf(b, ".SH COMMANDS\n")
+
+		for _, c := range ld.Commands {
+			fmt.Fprintf(b, ".TP\n.B %s\n", roffEscape(c.Name))
+			if len(c.Help) != 0 {
+				fmt.Fprintf(b, "%s\n", roffEscape(c.Help))
+			}
+		}
+	}
+
+	if opts := ld.options(makeConfigNode(cfg)); len(opts) != 0 {
+		fmt.Fprintf(b, ".SH OPTIONS\n")
+		ld.writeManOptions(b, opts)
+	}
+
+	return b.Flush()
+}
+
+// FprintMarkdown writes to w the reference documentation of a program loading
+// its configuration into cfg with ld, in the Markdown format.
+//
+// The document carries the same information than the man page generated by
+// FprintManPage.
+func (ld Loader) FprintMarkdown(w io.Writer, cfg interface{}) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "# %s\n\n", ld.Name)
+	fmt.Fprintf(b, "## Usage\n\n```\n%s\n```\n", ld.usage())
+
+	if len(ld.Commands) != 0 {
+		fmt.Fprintf(b, "\n## Commands\n\n")
+		fmt.Fprintf(b, "| Command | Description |\n")
+		fmt.Fprintf(b, "| ------- | ----------- |\n")
+
+		for _, c := range ld.Commands {
+			fmt.Fprintf(b, "| %s | %s |\n", markdownCode(c.Name), markdownEscape(c.Help))
+		}
+	}
+
+	if opts := ld.options(makeConfigNode(cfg)); len(opts) != 0 {
+		fmt.Fprintf(b, "\n## Options\n\n")
+		ld.writeMarkdownOptions(b, opts)
+	}
+
+	return b.Flush()
+}
+
+// FprintCommandManPage writes to w the man page of a program made of the command
+// tree rooted at root, in the roff format. It documents the commands of the tree
+// and their options, the options of each command are listed after those of its
+// parent, without the ones inherited from it.
+func (ld Loader) FprintCommandManPage(w io.Writer, root Cmd) error {
+	b := bufio.NewWriter(w)
+	nodes := ld.referenceNodes(root)
+
+	fmt.Fprintf(b, ".TH %s 1\n", roffQuote(strings.ToUpper(ld.Name)))
+	fmt.Fprintf(b, ".SH NAME\n%s\n", roffEscape(ld.Name))
+	fmt.Fprintf(b, ".SH SYNOPSIS\n%s\n", roffEscape(nodes[0].usage))
+
+	if len(root.Help) != 0 {
+		fmt.Fprintf(b, ".SH DESCRIPTION\n%s\n", roffEscape(root.Help))
+	}
+
+	if len(nodes) > 1 {
+		fmt.Fprintf(b, ".SH COMMANDS\n")
+
+		for _, n := range nodes[1:] {
+			fmt.Fprintf(b, ".TP\n.B %s\n", roffEscape(n.name))
+			if len(n.help) != 0 {
+				fmt.Fprintf(b, "%s\n", roffEscape(n.help))
+			}
+		}
+	}
+
+	if opts := nodes[0].options; len(opts) != 0 {
+		fmt.Fprintf(b, ".SH OPTIONS\n")
+		ld.writeManOptions(b, opts)
+	}
+
+	for _, n := range nodes[1:] {
+		if len(n.options) != 0 {
+			fmt.Fprintf(b, ".SS %s\n", roffQuote(ld.Name+" "+n.name))
+			ld.writeManOptions(b, n.options)
+		}
+	}
+
+	return b.Flush()
+}
+
+// FprintCommandMarkdown writes to w the reference documentation of a program
+// made of the command tree rooted at root, in the Markdown format.
+//
+// The document carries the same information than the man page generated by
+// FprintCommandManPage.
+func (ld Loader) FprintCommandMarkdown(w io.Writer, root Cmd) error {
+	b := bufio.NewWriter(w)
+	nodes := ld.referenceNodes(root)
+
+	fmt.Fprintf(b, "# %s\n\n", ld.Name)
+
+	if len(root.Help) != 0 {
+		fmt.Fprintf(b, "%s\n\n", root.Help)
+	}
+
+	fmt.Fprintf(b, "## Usage\n\n```\n%s\n```\n", nodes[0].usage)
+
+	if len(nodes) > 1 {
+		fmt.Fprintf(b, "\n## Commands\n\n")
+		fmt.Fprintf(b, "| Command | Description |\n")
+		fmt.Fprintf(b, "| ------- | ----------- |\n")
+
+		for _, n := range nodes[1:] {
+			fmt.Fprintf(b, "| %s | %s |\n", markdownCode(n.name), markdownEscape(n.help))
+		}
+	}
+
+	if opts := nodes[0].options; len(opts) != 0 {
+		fmt.Fprintf(b, "\n## Options\n\n")
+		ld.writeMarkdownOptions(b, opts)
+	}
+
+	for _, n := range nodes[1:] {
+		if len(n.options) != 0 {
+			fmt.Fprintf(b, "\n### Options of `%s %s`\n\n", ld.Name, n.name)
+			ld.writeMarkdownOptions(b, n.options)
+		}
+	}
+
+	return b.Flush()
+}
+
+// referenceNode is a command of a tree documented in reference documentation,
+// its name is the list of command names leading to it, separated by spaces.
+type referenceNode struct {
+	name    string
+	help    string
+	usage   string
+	options []option // options of the command not inherited from its parent
+}
+
+// referenceNodes returns the commands of the tree rooted at root, the root
+// first and then the other commands in depth-first order.
+func (ld Loader) referenceNodes(root Cmd) (nodes []referenceNode) {
+	var walk func([]Cmd, string, map[string]bool)
+
+	walk = func(path []Cmd, name string, inherited map[string]bool) {
+		cmd := path[len(path)-1]
+		node := referenceNode{name: name, help: cmd.Help, usage: ld.commandUsage(path)}
+		seen := make(map[string]bool, len(inherited))
+
+		for _, o := range ld.options(makeCommandNode(path)) {
+			if !inherited[o.name] {
+				node.options = append(node.options, o)
+			}
+			seen[o.name] = true
+		}
+
+		nodes = append(nodes, node)
+
+		for _, c := range cmd.Commands {
+			walk(append(path[:len(path):len(path)], c), strings.TrimSpace(name+" "+c.Name), seen)
+		}
+	}
+
+	walk([]Cmd{root}, "", nil)
+	return
+}
+
+// writeManOptions writes the list of options opts to b in the roff format.
+func (ld Loader) writeManOptions(b *bufio.Writer, opts []option) {
+	for _, o := range opts {
+		var lines []string
+
+		if o.boolean {
+			fmt.Fprintf(b, ".TP\n.B %s\n", roffEscape(ld.optionName(o)))
+		} else {
+			fmt.Fprintf(b, ".TP\n.BI %s \" %s\"\n", roffEscape(ld.optionName(o)), roffEscape(o.typ))
+		}
+
+		if len(o.help) != 0 {
+			lines = append(lines, roffEscape(o.help))
+		}
+		if len(o.defval) != 0 {
+			lines = append(lines, "Default: "+roffEscape(o.defval))
+		}
+		if o.required {
+			lines = append(lines, "Required.")
+		}
+		for _, k := range o.keys {
+			lines = append(lines, keyLabel(k)+": \\fB"+roffEscape(k.Key)+"\\fR")
+		}
+		if len(o.key) != 0 {
+			lines = append(lines, "Configuration file key: \\fB"+roffEscape(o.key)+"\\fR")
+		}
+
+		fmt.Fprintf(b, "%s\n", strings.Join(lines, "\n.br\n"))
+	}
+}
+
+// writeMarkdownOptions writes the list of options opts to b as a Markdown
+// table.
+func (ld Loader) writeMarkdownOptions(b *bufio.Writer, opts []option) {
+	fmt.Fprintf(b, "| Option | Type | Description | Default | Sources | Configuration file key |\n")
+	fmt.Fprintf(b, "| ------ | ---- | ----------- | ------- | ------- | ---------------------- |\n")
+
+	for _, o := range opts {
+		typ, help := o.typ, o.help
+		if o.boolean {
+			typ = "bool"
+		}
+		if o.required {
+			help = strings.TrimSpace(help + " (required)")
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s |\n",
+			markdownCode(ld.optionName(o)),
+			markdownEscape(typ),
+			markdownEscape(help),
+			markdownCode(o.defval),
+			markdownKeys(o.keys),
+			markdownCode(o.key),
+		)
+	}
+}
+
+// keyLabel returns the label introducing the key k in reference documentation.
+func keyLabel(k Origin) string {
+	switch k.Source {
+	case "env":
+		return "Environment variable"
+	case "dotenv":
+		return "Dotenv variable"
+	case "configmap":
+		return "ConfigMap entry"
+	default:
+		return "Key of the " + k.Source + " source"
+	}
+}
+
+// roffEscape escapes s so it's displayed as-is by roff.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	lines := strings.Split(s, "\n")
+
+	// Lines starting with a dot or a quote would be interpreted as requests.
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func roffQuote(s string) string {
+	return `"` + strings.ReplaceAll(roffEscape(s), `"`, `\(dq`) + `"`
+}
+
+// markdownEscape escapes s to be written in a cell of a Markdown table.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "\n", " ").Replace(s)
+}
+
+// markdownKeys formats the keys of an option in a cell of a Markdown table.
+func markdownKeys(keys []Origin) string {
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = markdownEscape(k.Source) + " " + markdownCode(k.Key)
+	}
+	return strings.Join(s, "<br>")
+}
+
+// markdownCode formats s as inline code in a cell of a Markdown table, empty
+// strings are left empty.
+func markdownCode(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return "`" + strings.NewReplacer("|", `\|`, "`", "'", "\n", " ").Replace(s) + "`"
+}
@@ -0,0 +1,229 @@
+package conf
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+type referenceConfig struct {
+	Verbose  bool          `conf:"verbose" help:"Enable verbose output"`
+	Timeout  time.Duration `conf:"timeout" help:"Time limit | per request"`
+	Password Secret        `conf:"password"`
+	DB       struct {
+		Host string `conf:"host" help:"Database host"`
+	} `conf:"db"`
+}
+
+func makeReferenceTest() (Loader, *referenceConfig) {
+	cfg := &referenceConfig{Timeout: time.Second, Password: "hunter2"}
+	cfg.DB.Host = "localhost"
+
+	ld := Loader{
+		Name:     "test",
+		Commands: []Command{{"run", "Run the program"}},
+		Sources: []Source{
+			NewFileSource("config-file", nil, os.ReadFile, nil),
+			NewEnvSource("test"),
+		},
+	}
+
+	return ld, cfg
+}
+
+func TestFprintManPage(t *testing.T) {
+	ld, cfg := makeReferenceTest()
+	b := &bytes.Buffer{}
+
+	if err := ld.FprintManPage(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = `.TH "TEST" 1
+.SH NAME
+test
+.SH SYNOPSIS
+test [command] [options...]
+.SH COMMANDS
+.TP
+.B run
+Run the program
+.SH OPTIONS
+.TP
+.BI \-config\-file " source"
+Location to load the configuration file from, may be repeated to merge multiple files.
+.TP
+.BI \-db " object"
+Environment variable: \fBTEST_DB\fR
+.br
+Configuration file key: \fBdb\fR
+.TP
+.BI \-db.host " string"
+Database host
+.br
+Default: localhost
+.br
+Environment variable: \fBTEST_DB_HOST\fR
+.br
+Configuration file key: \fBdb.host\fR
+.TP
+.BI \-password " secret"
+Default: <redacted>
+.br
+Environment variable: \fBTEST_PASSWORD\fR
+.br
+Configuration file key: \fBpassword\fR
+.TP
+.BI \-timeout " duration"
+Time limit | per request
+.br
+Default: 1s
+.br
+Environment variable: \fBTEST_TIMEOUT\fR
+.br
+Configuration file key: \fBtimeout\fR
+.TP
+.B \-verbose
+Enable verbose output
+.br
+Environment variable: \fBTEST_VERBOSE\fR
+.br
+Configuration file key: \fBverbose\fR
+`
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
+
+func TestFprintMarkdown(t *testing.T) {
+	ld, cfg := makeReferenceTest()
+	b := &bytes.Buffer{}
+
+	if err := ld.FprintMarkdown(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = "# test\n" +
+		"\n" +
+		"## Usage\n" +
+		"\n" +
+		"```\n" +
+		"test [command] [options...]\n" +
+		"```\n" +
+		"\n" +
+		"## Commands\n" +
+		"\n" +
+		"| Command | Description |\n" +
+		"| ------- | ----------- |\n" +
+		"| `run` | Run the program |\n" +
+		"\n" +
+		"## Options\n" +
+		"\n" +
+		"| Option | Type | Description | Default | Sources | Configuration file key |\n" +
+		"| ------ | ---- | ----------- | ------- | ------- | ---------------------- |\n" +
+		"| `-config-file` | source | Location to load the configuration file from, may be repeated to merge multiple files. |  |  |  |\n" +
+		"| `-db` | object |  |  | env `TEST_DB` | `db` |\n" +
+		"| `-db.host` | string | Database host | `localhost` | env `TEST_DB_HOST` | `db.host` |\n" +
+		"| `-password` | secret |  | `<redacted>` | env `TEST_PASSWORD` | `password` |\n" +
+		"| `-timeout` | duration | Time limit \\| per request | `1s` | env `TEST_TIMEOUT` | `timeout` |\n" +
+		"| `-verbose` | bool | Enable verbose output |  | env `TEST_VERBOSE` | `verbose` |\n"
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
+
+func makeCommandReferenceTest() (Loader, Cmd) {
+	var root struct {
+		Verbose bool `conf:"verbose" help:"Enable verbose output"`
+	}
+	var migrate struct {
+		Steps int `conf:"steps" help:"Number of migrations to apply"`
+	}
+	var down struct {
+		Force bool `conf:"force"`
+	}
+
+	ld := Loader{
+		Name:    "db",
+		Sources: []Source{NewEnvSource("db")},
+	}
+
+	cmd := Cmd{
+		Help:   "Manage the database",
+		Config: &root,
+		Commands: []Cmd{
+			{Name: "status", Help: "Show the schema version"},
+			{Name: "migrate", Help: "Apply migrations", Config: &migrate, Commands: []Cmd{
+				{Name: "down", Help: "Revert migrations", Config: &down},
+			}},
+		},
+	}
+
+	return ld, cmd
+}
+
+func TestFprintCommandManPage(t *testing.T) {
+	ld, cmd := makeCommandReferenceTest()
+	b := &bytes.Buffer{}
+
+	if err := ld.FprintCommandManPage(b, cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = ".TH \"DB\" 1\n" +
+		".SH NAME\ndb\n" +
+		".SH SYNOPSIS\ndb [command] [options...]\n" +
+		".SH DESCRIPTION\nManage the database\n" +
+		".SH COMMANDS\n" +
+		".TP\n.B status\nShow the schema version\n" +
+		".TP\n.B migrate\nApply migrations\n" +
+		".TP\n.B migrate down\nRevert migrations\n" +
+		".SH OPTIONS\n" +
+		".TP\n.B \\-verbose\nEnable verbose output\n.br\nEnvironment variable: \\fBDB_VERBOSE\\fR\n" +
+		".SS \"db migrate\"\n" +
+		".TP\n.BI \\-steps \" int\"\nNumber of migrations to apply\n.br\nEnvironment variable: \\fBDB_STEPS\\fR\n" +
+		".SS \"db migrate down\"\n" +
+		".TP\n.B \\-force\nEnvironment variable: \\fBDB_FORCE\\fR\n"
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
+
+func TestFprintCommandMarkdown(t *testing.T) {
+	ld, cmd := makeCommandReferenceTest()
+	b := &bytes.Buffer{}
+
+	if err := ld.FprintCommandMarkdown(b, cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = "# db\n\n" +
+		"Manage the database\n\n" +
+		"## Usage\n\n```\ndb [command] [options...]\n```\n" +
+		"\n## Commands\n\n" +
+		"| Command | Description |\n" +
+		"| ------- | ----------- |\n" +
+		"| `status` | Show the schema version |\n" +
+		"| `migrate` | Apply migrations |\n" +
+		"| `migrate down` | Revert migrations |\n" +
+		"\n## Options\n\n" +
+		"| Option | Type | Description | Default | Sources | Configuration file key |\n" +
+		"| ------ | ---- | ----------- | ------- | ------- | ---------------------- |\n" +
+		"| `-verbose` | bool | Enable verbose output |  | env `DB_VERBOSE` |  |\n" +
+		"\n### Options of `db migrate`\n\n" +
+		"| Option | Type | Description | Default | Sources | Configuration file key |\n" +
+		"| ------ | ---- | ----------- | ------- | ------- | ---------------------- |\n" +
+		"| `-steps` | int | Number of migrations to apply |  | env `DB_STEPS` |  |\n" +
+		"\n### Options of `db migrate down`\n\n" +
+		"| Option | Type | Description | Default | Sources | Configuration file key |\n" +
+		"| ------ | ---- | ----------- | ------- | ------- | ---------------------- |\n" +
+		"| `-force` | bool |  |  | env `DB_FORCE` |  |\n"
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,208 @@
+package conf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Resolver is the interface implemented by types that resolve references to
+// configuration values stored outside of the configuration sources, like
+// secrets mounted as files or kept in a secret manager.
+//
+// Resolvers are registered on a Loader by URL scheme. After all sources were
+// loaded, string values of the form "scheme://..." or "ref+scheme://..." where
+// scheme is the scheme of a registered resolver are replaced by the value that
+// the resolver returns for the reference. A resolver registered for a scheme
+// with the "ref+" prefix only resolves the references that use the prefix.
+//
+// The URL passed to the resolver never has the "ref+" prefix.
+type Resolver interface {
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// ResolverFunc makes it possible to use basic function types as resolvers.
+type ResolverFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+// Resolve calls f.
+func (f ResolverFunc) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	return f(ctx, ref)
+}
+
+// NewFileResolver creates a resolver which replaces references like
+// file:///run/secrets/db with the content of the file, read by calling
+// readFile (usually os.ReadFile). A trailing newline is removed from the value.
+//
+// References like file://secrets/db are relative to the working directory of
+// the program.
+func NewFileResolver(readFile func(string) ([]byte, error)) Resolver {
+	return ResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		b, err := readFile(filepath.FromSlash(ref.Host + ref.Path))
+		if err != nil {
+			return "", err
+		}
+		return string(bytes.TrimSuffix(b, []byte{'\n'})), nil
+	})
+}
+
+// NewEnvResolver creates a resolver which replaces references like env://NAME
+// with the value of the variable NAME in env.
+func NewEnvResolver(env ...string) Resolver {
+	vars := makeEnvVars(env)
+	return ResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		v, ok := vars[ref.Host]
+		if !ok {
+			return "", fmt.Errorf("environment variable not set: %s", ref.Host)
+		}
+		return v, nil
+	})
+}
+
+// NewHTTPResolver creates a resolver which replaces references like
+// https://vault/secret with the body of the response to a GET request sent to
+// the URL with client, or http.DefaultClient if it is nil. A trailing newline
+// is removed from the value.
+//
+// Registering the resolver for the "http" or "https" schemes would resolve all
+// URLs found in the configuration, it is usually registered for "ref+https" so
+// only explicit references like ref+https://vault/secret are resolved.
+func NewHTTPResolver(client *http.Client) Resolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return ResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.String(), nil)
+		if err != nil {
+			return "", err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return "", fmt.Errorf("GET %s: %s", ref.Redacted(), res.Status)
+		}
+
+		return string(bytes.TrimSuffix(b, []byte{'\n'})), nil
+	})
+}
+
+// resolve replaces the references found in the string values of node with the
+// values returned by the resolvers of ld.
+func (ld Loader) resolve(ctx context.Context, node Map) error {
+	if len(ld.Resolvers) == 0 {
+		return nil
+	}
+	return ld.resolveNode(ctx, nil, node)
+}
+
+func (ld Loader) resolveNode(ctx context.Context, path []string, node Node) error {
+	var errs errorList
+
+	switch n := node.(type) {
+	case Scalar:
+		return ld.resolveScalar(ctx, path, n)
+
+	case Array:
+		for i, item := range n.Items() {
+			errs = errs.append(ld.resolveNode(ctx, append(path, fmt.Sprint(i)), item))
+		}
+
+	case Map:
+		if n.value.Kind() == reflect.Struct {
+			for _, item := range n.Items() {
+				errs = errs.append(ld.resolveNode(ctx, append(path, item.Name), item.Value))
+			}
+			break
+		}
+
+		// Values of Go maps are not addressable, they are copied before being
+		// resolved then set back in the map.
+		for _, item := range n.Items() {
+			name, err := parseMapKey(item.Name, n.value.Type().Key())
+			if err != nil {
+				errs = errs.append(err)
+				continue
+			}
+
+			elem := reflect.New(n.value.Type().Elem())
+			elem.Elem().Set(n.value.MapIndex(name))
+
+			if err := ld.resolveNode(ctx, append(path, item.Name), makeNode(elem)); err != nil {
+				errs = errs.append(err)
+				continue
+			}
+
+			n.value.SetMapIndex(name, elem.Elem())
+			n.items.put(MapItem{
+				Name:  item.Name,
+				Value: makeNode(n.value.MapIndex(name)),
+			})
+		}
+	}
+
+	return errs.err()
+}
+
+func (ld Loader) resolveScalar(ctx context.Context, path []string, s Scalar) error {
+	if !s.value.IsValid() || s.value.Kind() != reflect.String || !s.value.CanSet() {
+		return nil
+	}
+
+	ref, resolver, err := ld.reference(s.value.String())
+	if resolver == nil || err != nil {
+		if err != nil {
+			err = fmt.Errorf("invalid reference found in %s: %w", strings.Join(path, "."), err)
+		}
+		return err
+	}
+
+	v, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("cannot resolve the value of %s: %w", strings.Join(path, "."), err)
+	}
+
+	s.value.SetString(v)
+	return nil
+}
+
+// reference parses s as a reference to a value, it returns a nil resolver if s
+// is not a reference that one of the resolvers of ld can resolve.
+//
+// References with the "ref+" prefix are first looked up with the prefix, then
+// with the scheme only.
+func (ld Loader) reference(s string) (*url.URL, Resolver, error) {
+	i := strings.Index(s, "://")
+	if i <= 0 {
+		return nil, nil, nil
+	}
+
+	resolver, ok := ld.Resolvers[s[:i]]
+	if !ok && strings.HasPrefix(s, "ref+") {
+		resolver, ok = ld.Resolvers[s[4:i]]
+	}
+	if !ok {
+		return nil, nil, nil
+	}
+
+	u, err := url.Parse(strings.TrimPrefix(s, "ref+"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return u, resolver, nil
+}
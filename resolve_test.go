@@ -0,0 +1,135 @@
+package conf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	const secretFile = "/tmp/conf-test-secret"
+	os.WriteFile(secretFile, []byte("hunter2\n"), 0600)
+	defer os.Remove(secretFile)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Write([]byte("abcdef\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	type config struct {
+		Password Secret            `conf:"password"`
+		Token    string            `conf:"token"`
+		User     *string           `conf:"user"`
+		Hosts    []string          `conf:"hosts"`
+		Keys     map[string]string `conf:"keys"`
+		Homepage string            `conf:"homepage"`
+		Name     string            `conf:"name" validate:"nonzero"`
+	}
+
+	ld := Loader{
+		Name: "test",
+		Args: []string{
+			"-password", "file://" + secretFile,
+			"-token", "ref+" + server.URL + "/token",
+			"-homepage", server.URL + "/token",
+			"-hosts", "[env://HOST_A, ref+env://HOST_B, localhost]",
+			"-keys", "{a: ref+file://" + secretFile + "}",
+		},
+		Sources: []Source{
+			NewEnvSource("test", "TEST_USER=env://USER_NAME", "TEST_NAME=ref+env://USER_NAME"),
+		},
+		Resolvers: map[string]Resolver{
+			"file":     NewFileResolver(os.ReadFile),
+			"env":      NewEnvResolver("HOST_A=host-a", "HOST_B=host-b", "USER_NAME=admin"),
+			"ref+http": NewHTTPResolver(nil),
+		},
+	}
+
+	var cfg config
+
+	if _, _, err := ld.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	user := "admin"
+	expected := config{
+		Password: "hunter2",
+		Token:    "abcdef",
+		User:     &user,
+		Hosts:    []string{"host-a", "host-b", "localhost"},
+		Keys:     map[string]string{"a": "hunter2"},
+		Homepage: server.URL + "/token",
+		Name:     "admin",
+	}
+
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("bad value:\n<<< %#v\n>>> %#v", expected, cfg)
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		ld := Loader{
+			Name:      "test",
+			Args:      []string{"-token", "ref+" + server.URL + "/missing"},
+			Resolvers: map[string]Resolver{"http": NewHTTPResolver(server.Client())},
+		}
+
+		var cfg struct {
+			Token string `conf:"token"`
+		}
+
+		_, _, err := ld.Load(&cfg)
+
+		if err == nil || err.Error() != "cannot resolve the value of token: GET "+server.URL+"/missing: 404 Not Found" {
+			t.Error("bad error:", err)
+		}
+	})
+}
+
+func TestEnvResolver(t *testing.T) {
+	r := NewEnvResolver("A=1")
+
+	if v, err := r.Resolve(context.Background(), &url.URL{Scheme: "env", Host: "A"}); err != nil || v != "1" {
+		t.Error("bad value:", v, err)
+	}
+
+	if _, err := r.Resolve(context.Background(), &url.URL{Scheme: "env", Host: "B"}); err == nil || err.Error() != "environment variable not set: B" {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestDefaultLoaderResolvers(t *testing.T) {
+	const secretFile = "/tmp/conf-test-default-secret"
+	os.WriteFile(secretFile, []byte("hunter2\n"), 0600)
+	defer os.Remove(secretFile)
+
+	var cfg struct {
+		URL      string `conf:"url"`
+		Home     string `conf:"home"`
+		Password string `conf:"password"`
+		Token    string `conf:"token"`
+	}
+
+	ld := defaultLoader([]string{"test",
+		"-url", "file://" + secretFile,
+		"-home", "env://HOME",
+		"-password", "ref+file://" + secretFile,
+		"-token", "ref+env://TOKEN",
+	}, []string{"TOKEN=abcdef"})
+
+	if _, _, err := ld.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.URL != "file://"+secretFile || cfg.Home != "env://HOME" || cfg.Password != "hunter2" || cfg.Token != "abcdef" {
+		t.Errorf("bad configuration: %+v", cfg)
+	}
+}
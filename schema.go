@@ -0,0 +1,301 @@
+package conf
+
+import (
+	"encoding"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/objconv"
+	"github.com/segmentio/objconv/json"
+)
+
+// FprintSchema writes to w a JSON Schema document describing the configuration
+// files that can be loaded into cfg.
+//
+// The schema is generated from the configuration struct: help messages become
+// descriptions, the current values of the fields (except secrets) become
+// defaults, and the min, max, len, nonzero and regexp rules of the "validate"
+// tags are translated to the equivalent schema keywords.
+func (ld Loader) FprintSchema(w io.Writer, cfg interface{}) error {
+	s := schema{
+		{"$schema", "https://json-schema.org/draft/2020-12/schema"},
+	}
+
+	if len(ld.Name) != 0 {
+		s = append(s, schemaKeyword{"title", ld.Name})
+	}
+
+	s = append(s, schemaOf(makeConfigNode(cfg), false)...)
+
+	if err := json.NewPrettyEncoder(w).Encode(s); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// schema is a JSON Schema object, it's represented as a list of keywords so
+// they are encoded in a deterministic order.
+type schema []schemaKeyword
+
+type schemaKeyword struct {
+	key   string
+	value interface{}
+}
+
+// set returns s with the keyword key set to value, replacing the previous value
+// of the keyword if s already had it.
+func (s schema) set(key string, value interface{}) schema {
+	for i := range s {
+		if s[i].key == key {
+			s[i].value = value
+			return s
+		}
+	}
+	return append(s, schemaKeyword{key, value})
+}
+
+func (s schema) EncodeValue(e objconv.Encoder) error {
+	i := 0
+	return e.EncodeMap(len(s), func(ke objconv.Encoder, ve objconv.Encoder) (err error) {
+		if err = ke.Encode(s[i].key); err != nil {
+			return
+		}
+		if err = ve.Encode(s[i].value); err != nil {
+			return
+		}
+		i++
+		return
+	})
+}
+
+// durationPattern matches the durations accepted by time.ParseDuration.
+const durationPattern = `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|μs|ms|s|m|h))+)$`
+
+// schemaOf returns the schema of the configuration node, secret is true when
+// the value of the node must not be exposed as a default.
+func schemaOf(node Node, secret bool) schema {
+	v := reflect.ValueOf(node.Value())
+	t := v.Type()
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch n := node.(type) {
+	case Map:
+		s := schema{{"type", "object"}}
+
+		if t.Kind() != reflect.Struct {
+			if pattern := mapKeyPattern(t.Key()); len(pattern) != 0 {
+				s = append(s, schemaKeyword{"propertyNames", schema{{"pattern", pattern}}})
+			}
+			s = append(s, schemaKeyword{"additionalProperties", schemaOf(makeNode(reflect.New(t.Elem()).Elem()), secret)})
+			if !secret && !isEmptyValue(v) {
+				s = append(s, schemaKeyword{"default", n})
+			}
+			return s
+		}
+
+		props := make(schema, 0, n.Len())
+
+		for _, item := range n.Items() {
+			p := schemaOf(item.Value, secret || item.Secret)
+
+			if len(item.Help) != 0 {
+				p = append(schema{{"description", item.Help}}, p...)
+			}
+
+			for _, rule := range schemaRules(item.Value, item.Validate) {
+				p = p.set(rule.key, rule.value)
+			}
+
+			props = append(props, schemaKeyword{item.Name, p})
+		}
+
+		return append(s, schemaKeyword{"properties", props})
+
+	case Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return schema{{"type", "string"}, {"contentEncoding", "base64"}}
+		}
+		s := schema{{"type", "array"}, {"items", schemaOf(makeNode(reflect.New(t.Elem()).Elem()), secret)}}
+		if !secret && !isEmptyValue(v) {
+			s = append(s, schemaKeyword{"default", n})
+		}
+		return s
+	}
+
+	var s schema
+
+	switch prettyType(t) {
+	case "duration":
+		s = schema{{"type", "string"}, {"pattern", durationPattern}}
+	case "time":
+		s = schema{{"type", "string"}, {"format", "date-time"}}
+	case "string", "secret":
+		s = schema{{"type", "string"}}
+	case "value", "unknown":
+		s = schema{}
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			s = schema{{"type", "boolean"}}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = schema{{"type", "integer"}}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			s = schema{{"type", "integer"}, {"minimum", 0}}
+		case reflect.Float32, reflect.Float64:
+			s = schema{{"type", "number"}}
+		case reflect.String:
+			s = schema{{"type", "string"}}
+		}
+	}
+
+	if !secret && !v.IsZero() {
+		def := node.Value()
+
+		// Values are written with the representation they have in the
+		// configuration files.
+		switch x := def.(type) {
+		case time.Duration:
+			def = x.String()
+		case time.Time:
+			def = x.Format(time.RFC3339Nano)
+		case encoding.TextMarshaler:
+			if b, err := x.MarshalText(); err == nil {
+				def = string(b)
+			}
+		}
+
+		if t.Kind() == reflect.String {
+			def = v.String()
+		}
+
+		s = append(s, schemaKeyword{"default", def})
+	}
+
+	return s
+}
+
+// schemaRules translates the rules of a "validate" tag to schema keywords,
+// rules which have no equivalent are ignored.
+func schemaRules(node Node, tag string) (s schema) {
+	if len(tag) == 0 {
+		return
+	}
+
+	t := reflect.TypeOf(node.Value())
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var min, max, length string
+
+	switch node.(type) {
+	case Map:
+		min, max, length = "minProperties", "maxProperties", ""
+	case Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		min, max, length = "minItems", "maxItems", ""
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			min, max, length = "minLength", "maxLength", ""
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			if prettyType(t) == "duration" {
+				return
+			}
+			min, max, length = "minimum", "maximum", "const"
+		default:
+			return
+		}
+	}
+
+	for _, rule := range splitRules(tag) {
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			switch name {
+			case "min":
+				s = append(s, schemaKeyword{min, n})
+			case "max":
+				s = append(s, schemaKeyword{max, n})
+			case "len":
+				if length == "" {
+					s = append(s, schemaKeyword{min, n}, schemaKeyword{max, n})
+				} else {
+					s = append(s, schemaKeyword{length, n})
+				}
+			}
+
+		case "nonzero":
+			if length == "" {
+				s = append(s, schemaKeyword{min, 1})
+			} else {
+				s = append(s, schemaKeyword{"not", schema{{"const", 0}}})
+			}
+
+		case "regexp":
+			if min == "minLength" {
+				s = append(s, schemaKeyword{"pattern", arg})
+			}
+		}
+	}
+
+	return
+}
+
+// splitRules splits the rules of a "validate" tag, commas may be escaped with
+// a backslash in the arguments of the rules.
+func splitRules(tag string) (rules []string) {
+	var b strings.Builder
+
+	for i := 0; i < len(tag); i++ {
+		switch c := tag[i]; {
+		case c == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			b.WriteByte(',')
+			i++
+		case c == ',':
+			rules = append(rules, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return append(rules, b.String())
+}
+
+// mapKeyPattern returns a regular expression matching the keys of maps with
+// keys of type t, or an empty string if any key is valid.
+func mapKeyPattern(t reflect.Type) string {
+	if isTextMapKey(t) {
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "^-?[0-9]+$"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "^[0-9]+$"
+	default:
+		return ""
+	}
+}
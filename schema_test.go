@@ -0,0 +1,169 @@
+package conf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFprintSchema(t *testing.T) {
+	type server struct {
+		Host string `conf:"host" help:"Host of the server" validate:"nonzero"`
+		Port uint16 `conf:"port" validate:"min=1,max=65535"`
+	}
+
+	cfg := struct {
+		Name     string            `conf:"name" help:"Name of the program" validate:"regexp=^[a-z]+$"`
+		Debug    bool              `conf:"debug"`
+		Ratio    float64           `conf:"ratio" validate:"min=0,max=1"`
+		Timeout  time.Duration     `conf:"timeout"`
+		Start    time.Time         `conf:"start"`
+		Key      []byte            `conf:"key"`
+		Tags     []string          `conf:"tags" validate:"len=2"`
+		Labels   map[string]string `conf:"labels"`
+		Password Secret            `conf:"password"`
+		DB       server            `conf:"db"`
+		Servers  []server          `conf:"servers"`
+	}{
+		Name:     "test",
+		Ratio:    0.5,
+		Timeout:  10 * time.Second,
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"team": "core"},
+		Password: "hunter2",
+		DB:       server{Host: "localhost", Port: 5432},
+	}
+
+	b := &bytes.Buffer{}
+
+	if err := (Loader{Name: "test"}).FprintSchema(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "test",
+  "type": "object",
+  "properties": {
+    "name": {
+      "description": "Name of the program",
+      "type": "string",
+      "default": "test",
+      "pattern": "^[a-z]+$"
+    },
+    "debug": {
+      "type": "boolean"
+    },
+    "ratio": {
+      "type": "number",
+      "default": 0.5,
+      "minimum": 0,
+      "maximum": 1
+    },
+    "timeout": {
+      "type": "string",
+      "pattern": "^[-+]?(0|([0-9]*(\\.[0-9]*)?(ns|us|µs|μs|ms|s|m|h))+)$",
+      "default": "10s"
+    },
+    "start": {
+      "type": "string",
+      "format": "date-time"
+    },
+    "key": {
+      "type": "string",
+      "contentEncoding": "base64"
+    },
+    "tags": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      },
+      "default": [
+        "a",
+        "b"
+      ],
+      "minItems": 2,
+      "maxItems": 2
+    },
+    "labels": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "string"
+      },
+      "default": {
+        "team": "core"
+      }
+    },
+    "password": {
+      "type": "string"
+    },
+    "db": {
+      "type": "object",
+      "properties": {
+        "host": {
+          "description": "Host of the server",
+          "type": "string",
+          "default": "localhost",
+          "minLength": 1
+        },
+        "port": {
+          "type": "integer",
+          "minimum": 1,
+          "default": 5432,
+          "maximum": 65535
+        }
+      }
+    },
+    "servers": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "host": {
+            "description": "Host of the server",
+            "type": "string",
+            "minLength": 1
+          },
+          "port": {
+            "type": "integer",
+            "minimum": 1,
+            "maximum": 65535
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+	if s := b.String(); s != txt {
+		t.Error(s)
+	}
+}
+
+func TestFprintSchemaMapKeys(t *testing.T) {
+	cfg := struct {
+		Ports map[int]string `conf:"ports"`
+	}{}
+
+	b := &bytes.Buffer{}
+
+	if err := (Loader{Name: "test"}).FprintSchema(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = `"ports": {
+      "type": "object",
+      "propertyNames": {
+        "pattern": "^-?[0-9]+$"
+      },
+      "additionalProperties": {
+        "type": "string"
+      }
+    }`
+
+	if s := b.String(); !strings.Contains(s, txt) {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,88 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Secret is a string type for configuration values that must not be exposed,
+// like passwords or API keys.
+//
+// Secret values are redacted when printed, in help messages, configuration
+// dumps and validation errors. The same behavior can be applied to fields of
+// any type by setting the `secret:"true"` tag on them.
+type Secret string
+
+// String satisfies the fmt.Stringer interface, it always returns a redacted
+// placeholder so secrets don't accidentally end up in logs.
+func (s Secret) String() string {
+	return redacted
+}
+
+// redacted is the placeholder printed instead of secret values.
+const redacted = "<redacted>"
+
+var secretType = reflect.TypeOf(Secret(""))
+
+func isSecretField(f reflect.StructField) bool {
+	return f.Tag.Get("secret") == "true" || f.Type == secretType
+}
+
+// secretPaths returns the set of dotted paths to the fields of m that hold
+// secrets, including fields nested in secret fields.
+func secretPaths(m Map) map[string]bool {
+	secrets := make(map[string]bool)
+
+	m.Scan(func(path []string, item MapItem) {
+		if item.Secret {
+			secrets[strings.Join(append(path, item.Name), ".")] = true
+		}
+	})
+
+	return secrets
+}
+
+// secretValue returns the string representation of the value of the field
+// identified by path in v, and whether the field holds a secret. The path is
+// made of Go field names separated by dots, as reported by the validator
+// package.
+func secretValue(v reflect.Value, path string) (value string, secret bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	name, next := path, ""
+	if sep := strings.IndexByte(path, '.'); sep >= 0 {
+		name, next = path[:sep], path[sep+1:]
+	}
+
+	field, ok := v.Type().FieldByName(name)
+	if !ok {
+		return
+	}
+
+	fv := v.FieldByIndex(field.Index)
+
+	if len(next) != 0 {
+		value, secret = secretValue(fv, next)
+		return value, secret || isSecretField(field)
+	}
+
+	if !isSecretField(field) {
+		return
+	}
+
+	if fv.Kind() == reflect.String {
+		return fv.String(), true
+	}
+
+	return fmt.Sprint(fv.Interface()), true
+}
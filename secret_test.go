@@ -0,0 +1,152 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	validator "gopkg.in/validator.v2"
+)
+
+type secretConfig struct {
+	User     string `conf:"user"`
+	Password Secret `conf:"password" help:"Password of the user"`
+	Token    string `conf:"token" secret:"true"`
+	Keys     struct {
+		Private string `conf:"private"`
+	} `conf:"keys" secret:"true"`
+}
+
+func makeSecretConfig() secretConfig {
+	cfg := secretConfig{
+		User:     "admin",
+		Password: "hunter2",
+		Token:    "abcdef",
+	}
+	cfg.Keys.Private = "xyz"
+	return cfg
+}
+
+func TestSecretString(t *testing.T) {
+	if s := fmt.Sprint(Secret("hunter2")); s != redacted {
+		t.Error("bad secret string:", s)
+	}
+
+	cfg := makeSecretConfig()
+
+	if s := MakeNode(cfg.Password).String(); s != redacted {
+		t.Error("bad scalar string:", s)
+	}
+
+	if s := MakeNode(&cfg).String(); s != "{ user: admin, password: <redacted> (Password of the user), token: <redacted>, keys: <redacted> }" {
+		t.Error("bad map string:", s)
+	}
+}
+
+func TestSecretHelp(t *testing.T) {
+	b := &bytes.Buffer{}
+	cfg := makeSecretConfig()
+	(Loader{Name: "test"}).FprintHelp(b, &cfg)
+
+	for _, s := range []string{"hunter2", "abcdef", "xyz"} {
+		if strings.Contains(b.String(), s) {
+			t.Errorf("secret %q found in help message:\n%s", s, b.String())
+		}
+	}
+
+	if !strings.Contains(b.String(), "  -password secret\n    \tPassword of the user (default <redacted>)\n") {
+		t.Errorf("secret default not redacted:\n%s", b.String())
+	}
+}
+
+func TestSecretPrintConfig(t *testing.T) {
+	b := &bytes.Buffer{}
+	cfg := makeSecretConfig()
+
+	if err := (Loader{}).FprintConfig(b, &cfg, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	const txt = "user: admin\npassword: <redacted>\ntoken: <redacted>\nkeys: <redacted>\n"
+
+	if s := b.String(); s != txt {
+		t.Errorf("bad output:\n%s", s)
+	}
+}
+
+func TestSecretValidationError(t *testing.T) {
+	validator.SetValidationFunc("notsecret", func(v interface{}, _ string) error {
+		return fmt.Errorf("%v is a bad password", reflect.ValueOf(v).String())
+	})
+
+	cfg := struct {
+		Password Secret `conf:"password" validate:"notsecret"`
+	}{
+		Password: "hunter2",
+	}
+
+	_, _, err := (Loader{}).Load(&cfg)
+
+	if err == nil || err.Error() != "invalid value passed to password: <redacted> is a bad password" {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestSecretNestedParseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		env    []string
+		secret string
+	}{
+		{
+			name:   "Flag",
+			args:   []string{"-db.port", "topsecretvalue"},
+			secret: "topsecretvalue",
+		},
+		{
+			name:   "Env",
+			env:    []string{"TEST_DB_PORT=hunter2secret"},
+			secret: "hunter2secret",
+		},
+		{
+			name:   "EnvEntry",
+			env:    []string{"TEST_DB_REPLICAS_0=hunter3secret"},
+			secret: "hunter3secret",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cfg struct {
+				DB struct {
+					Port     int   `conf:"port"`
+					Replicas []int `conf:"replicas"`
+				} `conf:"db" secret:"true"`
+			}
+
+			ld := Loader{
+				Name:    "test",
+				Args:    test.args,
+				Sources: []Source{NewEnvSource("test", test.env...)},
+			}
+
+			_, _, err := ld.Load(&cfg)
+			if err == nil {
+				t.Fatal("no error returned")
+			}
+
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Raw != redacted {
+				t.Error("bad error:", err)
+			}
+
+			if strings.Contains(err.Error(), test.secret) {
+				t.Error("secret found in error:", err)
+			}
+		})
+	}
+}
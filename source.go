@@ -3,6 +3,11 @@ package conf
 import (
 	"bytes"
 	"flag"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -37,6 +42,49 @@ type FlagSource interface {
 	flag.Value
 }
 
+// KeySource is an optional interface that sources may implement to tell users
+// where they look up the values of configuration fields. The keys are shown in
+// help messages, reference documentation, and in the errors reporting missing
+// required values.
+type KeySource interface {
+	Source
+
+	// Key returns the key that the source looks up for the configuration field
+	// at path. The Source field of the returned value names the kind of source
+	// (for example "env"), and the Key field is the key itself. A zero value is
+	// returned if the source has no key for the field.
+	Key(path []string) Origin
+}
+
+// keyDescription returns a description of where the key k can be set, to be
+// displayed to users.
+func keyDescription(k Origin) string {
+	switch k.Source {
+	case "env":
+		return "the " + k.Key + " environment variable"
+	case "dotenv":
+		return "the " + k.Key + " variable of " + k.File
+	case "configmap":
+		return "the " + k.Key + " entry of the ConfigMap mounted at " + k.File
+	case "flag":
+		return "the " + k.Key + " flag"
+	case "file":
+		return "the " + k.Key + " key of " + k.File
+	case "":
+		return "the " + k.Key + " key"
+	default:
+		return "the " + k.Key + " key of the " + k.Source + " source"
+	}
+}
+
+// strictSource is implemented by sources which can report the keys that don't
+// match any configuration field, loadStrict is called instead of Load when the
+// loader is in strict mode.
+type strictSource interface {
+	Source
+	loadStrict(dst Map) error
+}
+
 // SourceFunc makes it possible to use basic function types as configuration
 // sources.
 type SourceFunc func(dst Map) error
@@ -52,36 +100,423 @@ func (f SourceFunc) Load(dst Map) error {
 // A prefix may be set to namespace the environment variables that the source
 // will be looking at.
 func NewEnvSource(prefix string, env ...string) Source {
-	vars := makeEnvVars(env)
-	base := make([]string, 0, 10)
+	return &envSource{
+		prefix: prefix,
+		vars:   makeEnvVars(env),
+	}
+}
+
+type envSource struct {
+	prefix string
+	vars   map[string]string
+}
+
+func (e *envSource) Load(dst Map) error {
+	return e.load(dst, e.Key)
+}
+
+// load sets the fields of dst from the variables of the source, key returns
+// the origin reported by errors when a variable has an invalid value.
+func (e *envSource) load(dst Map, key func([]string) Origin) error {
+	var errs errorList
+	var entries []envEntries
+
+	seen := make(map[string]bool)
+
+	dst.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+		k := e.name(path)
+
+		// Values of maps are not addressable, they are set by loadEntries.
+		for _, entry := range entries {
+			if len(path) > len(entry.path) && reflect.DeepEqual(path[:len(entry.path)], entry.path) {
+				return
+			}
+		}
+
+		// Aliases may only differ in the case or separators of their names,
+		// which map to the same variable.
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+
+		if v, ok := e.vars[k]; ok {
+			// this only matches at the very end
+			if err := setValue(path[:len(path)-1], item, v, key(path)); err != nil {
+				errs = append(errs, err)
+			} else {
+				dst.loaded(path)
+			}
+
+			if len(deprecated) != 0 {
+				dst.deprecated(key(path), deprecated)
+			}
+		}
+
+		// Entries of slices and maps are set once the scan is done, because
+		// creating map entries would change the items being scanned.
+		if isContainer(item.Value) {
+			entries = append(entries, envEntries{
+				path: append([]string{}, path...),
+				item: item,
+			})
+		}
+	})
 
-	if prefix != "" {
-		base = append(base, prefix)
+	for _, entry := range entries {
+		errs = errs.append(e.loadEntries(entry.path, entry.item.Value, entry.item.Secret, seen, key))
 	}
 
-	return SourceFunc(func(dst Map) (err error) {
-		dst.Scan(func(path []string, item MapItem) {
-			path = append(base, path...)
-			path = append(path, item.Name)
+	return errs.err()
+}
 
-			k := snakecaseUpper(strings.Join(path, "_"))
+// envEntries is a slice or map found at path, which may have entries set by
+// indexed or keyed variables.
+type envEntries struct {
+	path []string
+	item MapItem
+}
 
-			if v, ok := vars[k]; ok {
-				// this only matches at the very end
-				if e := item.Value.Set(v); e != nil {
-					err = e
-				}
+// isContainer returns true if node is a slice or a map which may get new
+// entries from indexed or keyed variables.
+func isContainer(node Node) bool {
+	switch n := node.(type) {
+	case Array:
+		return true
+	case Map:
+		return n.value.Kind() == reflect.Map
+	default:
+		return false
+	}
+}
+
+// loadEntries sets the entries of the slice or map node found at path from the
+// variables named after the node and followed by an index or a key, for example
+// SERVERS_0_HOST or LABELS_TEAM. Slices grow and map entries are created as
+// needed, the keys of new map entries are the lowercased keys found in the
+// variable names. Variables in fields are ignored, they set other fields which
+// have names starting with the name of the node.
+func (e *envSource) loadEntries(path []string, node Node, secret bool, fields map[string]bool, key func([]string) Origin) error {
+	var errs errorList
+	var names []string
+
+	prefix := e.name(path) + "_"
+
+	for k := range e.vars {
+		if strings.HasPrefix(k, prefix) && !fields[k] {
+			names = append(names, k)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+
+	switch n := node.(type) {
+	case Array:
+		var indexes []int
+		found := make(map[int]bool)
+
+		for _, k := range names {
+			s := k[len(prefix):]
+			if i := strings.IndexByte(s, '_'); i >= 0 {
+				s = s[:i]
+			}
+			if i, err := strconv.Atoi(s); err == nil && i >= 0 && !found[i] {
+				found[i] = true
+				indexes = append(indexes, i)
+			}
+		}
+
+		sort.Ints(indexes)
+
+		// Indexes past the number of variables found can't be the result of
+		// describing a list, they are rejected so a typo doesn't make the
+		// slice grow to an arbitrary length.
+		limit := n.Len() + len(indexes)
+
+		for _, i := range indexes {
+			if i >= limit {
+				errs = append(errs, fmt.Errorf("index out of range in the %s%d environment variables: the list has %d elements", prefix, i, n.Len()))
+				continue
 			}
+			for n.Len() <= i {
+				n.push()
+			}
+			errs = errs.append(e.loadEntry(append(path, strconv.Itoa(i)), n.items.index(i), secret, fields, key))
+		}
+
+	case Map:
+		keys := mapEntryKeys(n, prefix, names)
+
+		for _, k := range keys {
+			name, err := parseMapKey(k, n.value.Type().Key())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid key found in the %s%s environment variables: %w", prefix, strings.ToUpper(k), err))
+				continue
+			}
+
+			// Values of maps are not addressable, existing entries are copied
+			// so the variables are merged with them.
+			elem := reflect.New(n.value.Type().Elem())
+			if prev := n.value.MapIndex(name); prev.IsValid() {
+				elem.Elem().Set(prev)
+			}
+
+			errs = errs.append(e.loadEntry(append(path, k), makeNode(elem), secret, fields, key))
+
+			n.value.SetMapIndex(name, elem.Elem())
+			n.items.put(MapItem{
+				Name:  mapKeyString(name),
+				Value: makeNode(n.value.MapIndex(name)),
+			})
+		}
+	}
+
+	return errs.err()
+}
+
+// mapEntryKeys returns the keys of the entries of the map m that the variables
+// in names set. The names all start with prefix, followed by the key and
+// optionally by the name of a field of the map values. Keys matching an entry
+// of m are returned as the key of the entry, other keys are lowercased.
+func mapEntryKeys(m Map, prefix string, names []string) (keys []string) {
+	var fields []string
+	var existing = make(map[string]string)
+	var found = make(map[string]bool)
+
+	if elem, ok := makeNode(reflect.New(m.value.Type().Elem())).(Map); ok {
+		elem.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+			fields = append(fields, (&envSource{}).name(path))
 		})
-		return
+	}
+
+	for _, item := range m.Items() {
+		existing[snakecaseUpper(item.Name)] = item.Name
+	}
+
+	for _, name := range names {
+		k := name[len(prefix):]
+
+		// The key is the longest part of the name followed by the name of a
+		// field, or the whole name if no field matches.
+		for _, f := range fields {
+			if s := strings.TrimSuffix(k, "_"+f); len(s) < len(k) && len(s) != 0 {
+				k = s
+				break
+			}
+		}
+
+		if found[k] {
+			continue
+		}
+		found[k] = true
+
+		if name, ok := existing[k]; ok {
+			keys = append(keys, name)
+		} else {
+			keys = append(keys, strings.ToLower(k))
+		}
+	}
+
+	return
+}
+
+// loadEntry sets node, an entry of a slice or a map at path, and its fields
+// from the variables of the source.
+func (e *envSource) loadEntry(path []string, node Node, secret bool, fields map[string]bool, key func([]string) Origin) error {
+	var errs errorList
+
+	if v, ok := e.vars[e.name(path)]; ok {
+		item := MapItem{Name: path[len(path)-1], Value: node, Secret: secret}
+		errs = errs.append(setValue(path[:len(path)-1], item, v, key(path)))
+	}
+
+	if m, ok := node.(Map); ok && m.value.Kind() == reflect.Struct {
+		for _, item := range m.Items() {
+			for _, name := range append([]string{item.Name}, item.Aliases...) {
+				errs = errs.append(e.loadEntry(append(path, name), item.Value, secret || item.Secret, fields, key))
+			}
+		}
+		return errs.err()
+	}
+
+	if isContainer(node) {
+		errs = errs.append(e.loadEntries(path, node, secret, fields, key))
+	}
+
+	return errs.err()
+}
+
+// isEntryVar returns true if name, the end of the name of a variable following
+// the name of the slice or map node, sets one of its entries. Slice entries are
+// set by an index, map entries by a key, either followed by the name of a field
+// of the elements. The keys of maps of structs must be followed by a field name
+// unless they have no underscores, otherwise typos in the field names would be
+// taken for keys.
+func isEntryVar(node Node, name string) bool {
+	switch n := node.(type) {
+	case Array:
+		index, rest := name, ""
+		if i := strings.IndexByte(name, '_'); i >= 0 {
+			index, rest = name[:i], name[i+1:]
+		}
+		if i, err := strconv.Atoi(index); err != nil || i < 0 {
+			return false
+		}
+		return len(rest) == 0 || isElemVar(makeNode(reflect.New(n.value.Type().Elem())), rest)
+
+	case Map:
+		elem, ok := makeNode(reflect.New(n.value.Type().Elem())).(Map)
+		if !ok || elem.value.Kind() != reflect.Struct {
+			return true
+		}
+		for i := 1; i < len(name); i++ {
+			if name[i] == '_' && isElemVar(elem, name[i+1:]) {
+				return true
+			}
+		}
+		return !strings.Contains(name, "_")
+	}
+
+	return false
+}
+
+// isElemVar returns true if name, the end of the name of a variable following
+// an index or a key, sets a field of the element node.
+func isElemVar(elem Node, name string) (found bool) {
+	switch e := elem.(type) {
+	case Array:
+		return isEntryVar(e, name)
+	case Map:
+		if e.value.Kind() != reflect.Struct {
+			return isEntryVar(e, name)
+		}
+		e.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+			k := (&envSource{}).name(path)
+			if name == k || (isContainer(item.Value) && strings.HasPrefix(name, k+"_") && isEntryVar(item.Value, name[len(k)+1:])) {
+				found = true
+			}
+		})
+	}
+	return
+}
+
+// loadStrict loads the environment variables like Load, and also reports the
+// variables starting with the prefix of the source which don't match any field.
+// Without a prefix there is no way to tell which variables were meant for the
+// program, none of them is reported.
+func (e *envSource) loadStrict(dst Map) error {
+	errs := errorList{}.append(e.Load(dst))
+
+	if len(e.prefix) != 0 {
+		errs = errs.append(e.unknownVars(dst, "env", ""))
+	}
+
+	return errs.err()
+}
+
+// unknownVars returns an error for each variable of the source which doesn't
+// match any field of dst. When the source has a prefix only the variables
+// starting with it are checked.
+func (e *envSource) unknownVars(dst Map, source string, file string) error {
+	var errs errorList
+	var names []string
+	var unknown []string
+
+	known := make(map[string]bool)
+
+	entries := make(map[string]Node)
+	var containers [][]string
+
+	dst.scanNames(func(path []string, item MapItem, alias bool, deprecated string) {
+		// The entries of maps are checked by isEntryVar, so keys loaded from
+		// the variables don't make them known.
+		for _, c := range containers {
+			if len(path) > len(c) && reflect.DeepEqual(path[:len(c)], c) {
+				return
+			}
+		}
+
+		k := e.name(path)
+		known[k] = true
+
+		if !alias {
+			names = append(names, k)
+		}
+
+		if isContainer(item.Value) {
+			entries[k+"_"] = item.Value
+			containers = append(containers, append([]string{}, path...))
+		}
 	})
+
+	prefix := ""
+	if len(e.prefix) != 0 {
+		prefix = e.name(nil) + "_"
+	}
+
+vars:
+	for k := range e.vars {
+		if !strings.HasPrefix(k, prefix) || known[k] {
+			continue
+		}
+		// Variables setting the entries of slices and maps have names which
+		// depend on the indexes and keys, they are accepted when the rest of
+		// the name matches the elements.
+		for entry, node := range entries {
+			if strings.HasPrefix(k, entry) && isEntryVar(node, k[len(entry):]) {
+				continue vars
+			}
+		}
+		unknown = append(unknown, k)
+	}
+
+	sort.Strings(unknown)
+
+	for _, k := range unknown {
+		errs = append(errs, &UnknownKeyError{
+			Source:     source,
+			Key:        k,
+			File:       file,
+			Suggestion: suggest(k, names),
+		})
+	}
+
+	return errs.err()
+}
+
+func (e *envSource) Origin(path []string) Origin {
+	return e.Key(path)
+}
+
+func (e *envSource) Key(path []string) Origin {
+	return Origin{Source: "env", Key: e.name(path)}
+}
+
+// name returns the name of the environment variable that the source looks up
+// for the configuration field at path.
+func (e *envSource) name(path []string) string {
+	if len(e.prefix) != 0 {
+		path = append([]string{e.prefix}, path...)
+	}
+	return snakecaseUpper(strings.Join(path, "_"))
 }
 
 // NewFileSource creates a new source which loads a configuration from a file
 // identified by a path (or URL).
 //
 // The returned source satisfies the FlagSource interface because it loads the
-// file location from the given flag.
+// file location from the given flag. The flag may be repeated, or set to a
+// comma-separated list of locations, to load multiple files in order, each one
+// being merged over the configuration loaded from the previous ones.
+//
+// A configuration file may include other files with an "include" or "extends"
+// key, set to a file or a list of files. Relative paths are resolved from the
+// directory of the including file, and the included files are loaded first so
+// the values of the including file take precedence.
 //
 // The vars argument may be set to render the configuration file if it's a
 // template.
@@ -90,7 +525,10 @@ func NewEnvSource(prefix string, env ...string) Source {
 // given as argument, usually this is ioutil.ReadFile.
 //
 // The unmarshal function decodes the content of the configuration file into a
-// configuration object.
+// configuration object. If it is nil the format of the file is detected from
+// its extension (.yaml, .yml, .json, .toml or .env), or from its content when
+// the extension is unknown. RegisterFileFormat may be used to support more file
+// formats.
 func NewFileSource(flag string, vars interface{}, readFile func(string) ([]byte, error), unmarshal func([]byte, interface{}) error) FlagSource {
 	return &fileSource{
 		flag:      flag,
@@ -102,20 +540,136 @@ func NewFileSource(flag string, vars interface{}, readFile func(string) ([]byte,
 
 type fileSource struct {
 	flag      string
-	path      string
+	paths     []string
 	vars      interface{}
 	readFile  func(string) ([]byte, error)
 	unmarshal func([]byte, interface{}) error
+	origins   map[string]Origin // origins of the values set by the last Load
+}
+
+func (f *fileSource) Load(dst Map) error {
+	return f.loadFiles(dst, false)
 }
 
-func (f *fileSource) Load(dst Map) (err error) {
+// loadStrict loads the configuration files like Load, and also reports the
+// keys of the files which don't match any configuration field.
+func (f *fileSource) loadStrict(dst Map) error {
+	return f.loadFiles(dst, true)
+}
+
+func (f *fileSource) loadFiles(dst Map, strict bool) error {
+	var errs errorList
+	f.origins = make(map[string]Origin)
+
+	// Errors don't prevent the next files from being loaded, so all problems
+	// can be reported at once.
+	for _, path := range f.paths {
+		errs = errs.append(f.load(dst, []string{path}, strict))
+	}
+
+	return errs.err()
+}
+
+// load decodes the last file of chain into dst, after loading the files that
+// it includes. The chain is the list of files that included each other, from
+// the file passed to the source's flag to the one being loaded.
+func (f *fileSource) load(dst Map, chain []string, strict bool) (err error) {
 	var b []byte
+	var includes []string
+
+	path := chain[len(chain)-1]
 
-	if len(f.path) == 0 {
+	defer func() {
+		if err != nil && len(chain) > 1 {
+			if _, ok := err.(*includeError); !ok {
+				err = &includeError{chain: chain, err: err}
+			}
+		}
+	}()
+
+	if b, err = f.render(path); err != nil {
 		return
 	}
 
-	if b, err = f.readFile(f.path); err != nil {
+	unmarshal := f.unmarshal
+	if unmarshal == nil {
+		unmarshal = detectFileFormat(path, b)
+	}
+
+	if includes, err = fileIncludes(b, unmarshal); err != nil {
+		return
+	}
+
+	// Included files are loaded first so the values of the including file take
+	// precedence over theirs.
+	for _, include := range includes {
+		if !filepath.IsAbs(include) && !strings.Contains(include, "://") {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		for _, p := range chain {
+			if p == include {
+				return &includeError{chain: append(chain, include), cycle: true}
+			}
+		}
+
+		if err = f.load(dst, append(chain[:len(chain):len(chain)], include), strict); err != nil {
+			return
+		}
+	}
+
+	before := snapshot(dst)
+	unknown := errorList{}
+	hooks := &decodeHooks{}
+
+	if parent := dst.hooks; parent != nil && parent.deprecated != nil {
+		hooks.deprecated = func(d Deprecation) {
+			d.Key.Source, d.Key.File = "file", path
+			parent.deprecated(d)
+		}
+	}
+
+	if parent := dst.hooks; parent != nil {
+		hooks.loaded = parent.loaded
+	}
+
+	if strict {
+		hooks.unknown = func(key []string, known []string) {
+			if len(key) == 1 && (key[0] == "include" || key[0] == "extends") {
+				return
+			}
+
+			e := &UnknownKeyError{Source: "file", Key: strings.Join(key, "."), File: path}
+
+			if s := suggest(key[len(key)-1], known); len(s) != 0 {
+				e.Suggestion = strings.Join(append(key[:len(key)-1:len(key)-1], s), ".")
+			}
+
+			unknown = append(unknown, e)
+		}
+	}
+
+	dst.hooks = hooks
+
+	if err = unmarshal(b, dst); err != nil {
+		return
+	}
+
+	updateOrigins(dst, before, f.origins, func(key []string) Origin {
+		return Origin{Source: "file", Key: strings.Join(key, "."), File: path}
+	})
+
+	// Some decoders don't preserve the order of keys, the errors are sorted so
+	// they are always reported the same way.
+	sort.Slice(unknown, func(i, j int) bool {
+		return unknown[i].(*UnknownKeyError).Key < unknown[j].(*UnknownKeyError).Key
+	})
+	return unknown.err()
+}
+
+// render reads the file at path and renders it as a template.
+func (f *fileSource) render(path string) (b []byte, err error) {
+	if b, err = f.readFile(path); err != nil {
 		return
 	}
 
@@ -138,23 +692,99 @@ func (f *fileSource) Load(dst Map) (err error) {
 		return
 	}
 
-	err = f.unmarshal(buf.Bytes(), dst)
+	b = buf.Bytes()
 	return
 }
 
+// fileIncludes returns the list of files that the configuration file with
+// content b includes through its "include" or "extends" keys, which may be set
+// to a single file or a list of files.
+func fileIncludes(b []byte, unmarshal func([]byte, interface{}) error) (includes []string, err error) {
+	var m map[string]interface{}
+
+	// Files that cannot be decoded into a generic map don't include other
+	// files, decoding errors are reported when they get loaded.
+	if unmarshal(b, &m) != nil {
+		return
+	}
+
+	for _, key := range []string{"extends", "include"} {
+		switch v := m[key].(type) {
+		case nil:
+		case string:
+			includes = append(includes, v)
+		case []interface{}:
+			for _, x := range v {
+				s, ok := x.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid value found in the %q list of files to include: %v", key, x)
+				}
+				includes = append(includes, s)
+			}
+		default:
+			return nil, fmt.Errorf("invalid value found for the %q file to include: %v", key, v)
+		}
+	}
+
+	return
+}
+
+// includeError wraps errors that occurred while loading a file included by
+// another configuration file, or reports include cycles.
+type includeError struct {
+	chain []string
+	cycle bool
+	err   error
+}
+
+func (e *includeError) Error() string {
+	if e.cycle {
+		return "include cycle detected in configuration files: " + strings.Join(e.chain, " -> ")
+	}
+	return "error loading included configuration file " + strings.Join(e.chain, " -> ") + ": " + e.err.Error()
+}
+
+func (e *includeError) Unwrap() error {
+	return e.err
+}
+
+func (f *fileSource) Origin(path []string) Origin {
+	key := strings.Join(path, ".")
+
+	if o, ok := f.origins[key]; ok {
+		return o
+	}
+
+	o := Origin{Source: "file", Key: key}
+
+	if n := len(f.paths); n != 0 {
+		o.File = f.paths[n-1]
+	}
+
+	return o
+}
+
 func (f *fileSource) Flag() string {
 	return f.flag
 }
 
 func (f *fileSource) Help() string {
-	return "Location to load the configuration file from."
+	return "Location to load the configuration file from, may be repeated to merge multiple files."
 }
 
 func (f *fileSource) Set(s string) error {
-	f.path = s
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.TrimSpace(path); len(path) != 0 {
+			f.paths = append(f.paths, path)
+		}
+	}
 	return nil
 }
 
 func (f *fileSource) String() string {
-	return f.path
+	return strings.Join(f.paths, ",")
+}
+
+func (f *fileSource) reset() {
+	f.paths = nil
 }
@@ -1,6 +1,10 @@
 package conf
 
 import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -45,4 +49,360 @@ func TestEnvSource(t *testing.T) {
 			t.Errorf("expected 'blah' stream name, got %q", cfg.StreamName)
 		}
 	})
+
+	t.Run("Entries", func(t *testing.T) {
+		type server struct {
+			Host string   `conf:"host"`
+			Port int      `conf:"port"`
+			Tags []string `conf:"tags"`
+		}
+
+		type config struct {
+			Servers      []server          `conf:"servers"`
+			ServersCount int               `conf:"servers_count"`
+			Labels       map[string]string `conf:"labels"`
+			Ports        map[int]server    `conf:"ports"`
+		}
+
+		cfg := config{
+			Servers: []server{{Host: "localhost", Port: 1}},
+			Labels:  map[string]string{"Team": "infra", "env": "dev"},
+			Ports:   map[int]server{80: {Host: "web", Port: 8080}},
+		}
+
+		src := NewEnvSource("app",
+			"APP_SERVERS_0_PORT=2",
+			"APP_SERVERS_1_HOST=remote",
+			"APP_SERVERS_1_TAGS_0=a",
+			"APP_SERVERS_1_TAGS_1=b",
+			"APP_SERVERS_COUNT=2",
+			"APP_LABELS_TEAM=core",
+			"APP_LABELS_OWNER_NAME=me",
+			"APP_PORTS_80_HOST=www",
+			"APP_PORTS_443_HOST=secure",
+			"APP_PORTS_443_PORT=8443",
+		)
+
+		if _, _, err := (Loader{Name: "app", Sources: []Source{src}}).Load(&cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := config{
+			Servers: []server{
+				{Host: "localhost", Port: 2},
+				{Host: "remote", Tags: []string{"a", "b"}},
+			},
+			ServersCount: 2,
+			Labels:       map[string]string{"Team": "core", "env": "dev", "owner_name": "me"},
+			Ports: map[int]server{
+				80:  {Host: "www", Port: 8080},
+				443: {Host: "secure", Port: 8443},
+			},
+		}
+
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("bad configuration:\n%+v\n%+v", cfg, expected)
+		}
+	})
+
+	t.Run("EntriesErrors", func(t *testing.T) {
+		var cfg struct {
+			Servers []struct {
+				Port int `conf:"port"`
+			} `conf:"servers"`
+			Ports map[int]string `conf:"ports"`
+		}
+
+		src := NewEnvSource("app", "APP_SERVERS_5_PORT=1", "APP_SERVERS_0_PORT=x", "APP_PORTS_HTTP=80")
+
+		_, _, err := (Loader{Name: "app", Sources: []Source{src}}).Load(&cfg)
+
+		errs, ok := err.(errorList)
+		if !ok || len(errs) != 3 {
+			t.Fatal("bad error:", err)
+		}
+
+		var perr *ParseError
+		if !errors.As(errs[0], &perr) || perr.Key != "APP_SERVERS_0_PORT" || perr.Path != "servers.0.port" {
+			t.Error("bad error:", errs[0])
+		}
+
+		if s := errs[1].Error(); s != "index out of range in the APP_SERVERS_5 environment variables: the list has 1 elements" {
+			t.Error("bad error:", s)
+		}
+
+		if s := errs[2].Error(); !strings.HasPrefix(s, `invalid key found in the APP_PORTS_HTTP environment variables: invalid map key "http"`) {
+			t.Error("bad error:", s)
+		}
+	})
+}
+
+func TestFileSourceLayers(t *testing.T) {
+	type server struct {
+		Host string `conf:"host"`
+		Port int    `conf:"port"`
+	}
+
+	type config struct {
+		Name    string            `conf:"name"`
+		DB      server            `conf:"db"`
+		Labels  map[string]string `conf:"labels"`
+		Servers map[string]server `conf:"servers"`
+	}
+
+	files := map[string]string{
+		"/tmp/conf-test-base.yaml":   "name: base\ndb: {host: localhost, port: 5432}\nlabels: {team: core}\nservers: {a: {host: a, port: 1}}\n",
+		"/tmp/conf-test-region.json": `{"db":{"host":"region"},"labels":{"region":"us"},"servers":{"a":{"port":2}}}`,
+		"/tmp/conf-test-local.yaml":  "name: local\n",
+	}
+
+	for file, content := range files {
+		os.WriteFile(file, []byte(content), 0644)
+		defer os.Remove(file)
+	}
+
+	var cfg config
+
+	ld := Loader{
+		Name: "test",
+		Args: []string{
+			"-config-file", "/tmp/conf-test-base.yaml,/tmp/conf-test-region.json",
+			"-config-file", "/tmp/conf-test-local.yaml",
+		},
+		Sources: []Source{
+			NewFileSource("config-file", nil, os.ReadFile, nil),
+		},
+	}
+
+	_, _, origins, err := ld.LoadWithOrigins(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := config{
+		Name:    "local",
+		DB:      server{Host: "region", Port: 5432},
+		Labels:  map[string]string{"team": "core", "region": "us"},
+		Servers: map[string]server{"a": {Host: "a", Port: 2}},
+	}
+
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("bad value:\n<<< %#v\n>>> %#v", expected, cfg)
+	}
+
+	for key, file := range map[string]string{
+		"name":    "/tmp/conf-test-local.yaml",
+		"db.host": "/tmp/conf-test-region.json",
+		"db.port": "/tmp/conf-test-base.yaml",
+	} {
+		if o := origins[key]; o.File != file {
+			t.Errorf("bad origin of %s: %s", key, o)
+		}
+	}
+}
+
+func TestFileSourceIncludes(t *testing.T) {
+	type config struct {
+		Name    string `conf:"name"`
+		Logging struct {
+			Level  string `conf:"level"`
+			Format string `conf:"format"`
+		} `conf:"logging"`
+		TLS struct {
+			Cert string `conf:"cert"`
+		} `conf:"tls"`
+	}
+
+	os.MkdirAll("/tmp/conf-test-includes/common", 0755)
+	defer os.RemoveAll("/tmp/conf-test-includes")
+
+	files := map[string]string{
+		"/tmp/conf-test-includes/app.yaml":            "extends: common/base.yaml\ninclude: [common/tls.json]\nname: app\nlogging: {level: debug}\n",
+		"/tmp/conf-test-includes/common/base.yaml":    "include: logging.yaml\nname: base\n",
+		"/tmp/conf-test-includes/common/logging.yaml": "logging: {level: info, format: json}\n",
+		"/tmp/conf-test-includes/common/tls.json":     `{"tls":{"cert":"cert.pem"}}`,
+		"/tmp/conf-test-includes/cycle-a.yaml":        "include: cycle-b.yaml\n",
+		"/tmp/conf-test-includes/cycle-b.yaml":        "include: cycle-a.yaml\n",
+		"/tmp/conf-test-includes/missing.yaml":        "include: common/missing.yaml\n",
+	}
+
+	for file, content := range files {
+		os.WriteFile(file, []byte(content), 0644)
+	}
+
+	load := func(file string) (cfg config, origins map[string]Origin, err error) {
+		ld := Loader{
+			Name:    "test",
+			Args:    []string{"-config-file", file},
+			Sources: []Source{NewFileSource("config-file", nil, os.ReadFile, nil)},
+		}
+		_, _, origins, err = ld.LoadWithOrigins(&cfg)
+		return
+	}
+
+	t.Run("Include", func(t *testing.T) {
+		cfg, origins, err := load("/tmp/conf-test-includes/app.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cfg.Name != "app" || cfg.Logging.Level != "debug" || cfg.Logging.Format != "json" || cfg.TLS.Cert != "cert.pem" {
+			t.Errorf("bad configuration: %+v", cfg)
+		}
+
+		if o := origins["logging.format"]; o.File != "/tmp/conf-test-includes/common/logging.yaml" {
+			t.Error("bad origin:", o)
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, _, err := load("/tmp/conf-test-includes/cycle-a.yaml")
+
+		const msg = "include cycle detected in configuration files: /tmp/conf-test-includes/cycle-a.yaml -> /tmp/conf-test-includes/cycle-b.yaml -> /tmp/conf-test-includes/cycle-a.yaml"
+
+		if err == nil || err.Error() != msg {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, _, err := load("/tmp/conf-test-includes/missing.yaml")
+
+		const msg = "error loading included configuration file /tmp/conf-test-includes/missing.yaml -> /tmp/conf-test-includes/common/missing.yaml: "
+
+		if err == nil || !strings.HasPrefix(err.Error(), msg) || !errors.Is(err, os.ErrNotExist) {
+			t.Error("bad error:", err)
+		}
+	})
+}
+
+func TestStrict(t *testing.T) {
+	type config struct {
+		MaxConnections int `conf:"max_connections"`
+		DB             struct {
+			Host string `conf:"host"`
+		} `conf:"db"`
+		Servers []struct {
+			Port int `conf:"port"`
+		} `conf:"servers"`
+		Labels map[string]string `conf:"labels"`
+	}
+
+	files := map[string]string{
+		"base.yaml": "db: {host: localhost}\n",
+		"app.yaml":  "include: base.yaml\nmax_conections: 10\ndb: {hots: remote}\nservers: [{port: 1}, {prot: 2}]\nlabels: {a: b}\n",
+		"app.toml":  "max_connections = 10\n[db]\nhost = \"localhost\"\nuser = \"root\"\n",
+	}
+
+	readFile := func(path string) ([]byte, error) {
+		return []byte(files[path]), nil
+	}
+
+	load := func(strict bool, file string, env ...string) error {
+		var cfg config
+		ld := Loader{
+			Name: "test",
+			Args: []string{"-config-file", file},
+			Sources: []Source{
+				NewFileSource("config-file", nil, readFile, nil),
+				NewEnvSource("test", env...),
+			},
+			Strict: strict,
+		}
+		_, _, err := ld.Load(&cfg)
+		return err
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		if err := load(false, "app.yaml", "TEST_MAX_CONECTIONS=1"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		err := load(true, "app.yaml")
+
+		errs, ok := err.(errorList)
+		if !ok {
+			t.Fatal("bad error:", err)
+		}
+
+		expected := []string{
+			"unknown key db.hots in app.yaml, did you mean db.host?",
+			"unknown key max_conections in app.yaml, did you mean max_connections?",
+			"unknown key servers.1.prot in app.yaml, did you mean servers.1.port?",
+		}
+
+		if len(errs) != len(expected) {
+			t.Fatal("bad errors:", errs)
+		}
+
+		for i, e := range errs {
+			if e.Error() != expected[i] {
+				t.Errorf("bad error #%d: %s", i, e)
+			}
+		}
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		err := load(true, "app.toml")
+
+		var e *UnknownKeyError
+		if !errors.As(err, &e) || e.Key != "db.user" || e.Suggestion != "" {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		err := load(true, "base.yaml", "TEST_MAX_CONECTIONS=1", "TEST_DB_HOST=localhost", "OTHER_VAR=1")
+
+		if err == nil || err.Error() != "unknown environment variable TEST_MAX_CONECTIONS, did you mean TEST_MAX_CONNECTIONS?" {
+			t.Error("bad error:", err)
+		}
+	})
+
+	t.Run("EnvEntries", func(t *testing.T) {
+		var cfg struct {
+			Servers []struct {
+				Host string   `conf:"host"`
+				Tags []string `conf:"tags"`
+			} `conf:"servers"`
+			Ports  map[string]struct{ Port int } `conf:"ports"`
+			Labels map[string]string             `conf:"labels"`
+		}
+
+		ld := Loader{
+			Name: "test",
+			Sources: []Source{NewEnvSource("test",
+				"TEST_SERVERS_0_HOST=a",
+				"TEST_SERVERS_0_TAGS_0=b",
+				"TEST_SERVERS_1_HSOT=c",
+				"TEST_SERVERS_X=d",
+				"TEST_PORTS_WEB_APP_PORT=80",
+				"TEST_PORTS_API={Port: 81}",
+				"TEST_PORTS_WEB_PROT=82",
+				"TEST_LABELS_TEAM_NAME=core",
+			)},
+			Strict: true,
+		}
+
+		_, _, err := ld.Load(&cfg)
+
+		errs, ok := err.(errorList)
+		if !ok {
+			t.Fatal("bad error:", err)
+		}
+
+		var keys []string
+		for _, e := range errs {
+			var u *UnknownKeyError
+			if errors.As(e, &u) {
+				keys = append(keys, u.Key)
+			}
+		}
+
+		if expected := []string{"TEST_PORTS_WEB_PROT", "TEST_SERVERS_1_HSOT", "TEST_SERVERS_X"}; !reflect.DeepEqual(keys, expected) {
+			t.Errorf("bad unknown variables: %q", keys)
+		}
+	})
 }
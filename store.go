@@ -0,0 +1,147 @@
+package conf
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds a configuration of type T which can be reloaded while the program
+// is running, for example when a Kubernetes ConfigMap that the program loads
+// its configuration from gets updated.
+//
+// Each reload builds a fresh configuration value with the store's loader, which
+// means going through all its sources, modifiers and validation. The new value
+// only replaces the current one if it was loaded successfully, otherwise the
+// store keeps the last valid configuration.
+//
+// The type T must be a struct type, the methods of Store are safe to use
+// concurrently from multiple goroutines.
+type Store[T any] struct {
+	loader   Loader
+	defaults func() T
+	value    atomic.Value // *T
+
+	mutex sync.Mutex // serializes reloads
+
+	listeners sync.Mutex // protects onChange and onError
+	onChange  []func(*T)
+	onError   []func(error)
+}
+
+// NewStore creates a store which loads configurations with ld. Before each load
+// the configuration is initialized with the value returned by defaults, or the
+// zero-value of T if defaults is nil.
+//
+// The configuration is loaded a first time before the function returns, an
+// error is returned if it couldn't be loaded.
+func NewStore[T any](ld Loader, defaults func() T) (*Store[T], error) {
+	s := &Store[T]{
+		loader:   ld,
+		defaults: defaults,
+	}
+
+	cfg, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.value.Store(cfg)
+	return s, nil
+}
+
+// Watch creates a store like NewStore does, then reloads it every time sub
+// reports a configuration change, until ctx is canceled.
+//
+// Errors that occur while reloading the configuration are reported to the
+// functions registered with OnError.
+func Watch[T any](ctx context.Context, ld Loader, sub Subscriber, defaults func() T) (*Store[T], error) {
+	s, err := NewStore(ld, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Subscribe(ctx, func(key, newValue string) {
+		if err := s.Reload(); err != nil {
+			s.notifyError(err)
+		}
+	})
+
+	return s, nil
+}
+
+// Load returns the current configuration of the store. The returned value must
+// not be modified.
+func (s *Store[T]) Load() *T {
+	return s.value.Load().(*T)
+}
+
+// Reload loads a new configuration, and swaps it with the current one if it was
+// loaded and validated successfully. Functions registered with OnChange are
+// called with the new configuration before Reload returns.
+//
+// If an error occurs the current configuration is kept and the error is
+// returned.
+func (s *Store[T]) Reload() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cfg, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	s.value.Store(cfg)
+	s.notifyChange(cfg)
+	return nil
+}
+
+// OnChange registers f to be called with the new configuration every time the
+// store is reloaded. Calls to f are serialized, and f must not call Reload.
+func (s *Store[T]) OnChange(f func(cfg *T)) {
+	s.listeners.Lock()
+	s.onChange = append(s.onChange, f)
+	s.listeners.Unlock()
+}
+
+// OnError registers f to be called with the errors that occur when the store
+// is reloaded in the background by Watch.
+func (s *Store[T]) OnError(f func(err error)) {
+	s.listeners.Lock()
+	s.onError = append(s.onError, f)
+	s.listeners.Unlock()
+}
+
+func (s *Store[T]) load() (*T, error) {
+	cfg := new(T)
+
+	if s.defaults != nil {
+		*cfg = s.defaults()
+	}
+
+	if _, _, err := s.loader.Load(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (s *Store[T]) notifyChange(cfg *T) {
+	s.listeners.Lock()
+	onChange := s.onChange
+	s.listeners.Unlock()
+
+	for _, f := range onChange {
+		f(cfg)
+	}
+}
+
+func (s *Store[T]) notifyError(err error) {
+	s.listeners.Lock()
+	onError := s.onError
+	s.listeners.Unlock()
+
+	for _, f := range onError {
+		f(err)
+	}
+}
@@ -0,0 +1,89 @@
+package conf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type storeConfig struct {
+	Host string `conf:"host" validate:"nonzero"`
+	Port int    `conf:"port"`
+}
+
+// testSubscriber is a Subscriber which lets tests trigger notifications.
+type testSubscriber struct {
+	f func(key, newValue string)
+}
+
+func (s *testSubscriber) Subscribe(ctx context.Context, f func(key, newValue string)) {
+	s.f = f
+}
+
+func (s *testSubscriber) Snapshot(ctx context.Context) (map[string]string, error) {
+	return nil, nil
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, value string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("host", "localhost")
+
+	ld := Loader{
+		Name:    "test",
+		Sources: []Source{NewKubernetesConfigMapSource("", dir)},
+	}
+
+	sub := &testSubscriber{}
+	store, err := Watch(context.Background(), ld, sub, func() storeConfig {
+		return storeConfig{Port: 8080}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []storeConfig
+	var errors []error
+	store.OnChange(func(cfg *storeConfig) { changes = append(changes, *cfg) })
+	store.OnError(func(err error) { errors = append(errors, err) })
+
+	if cfg := store.Load(); *cfg != (storeConfig{Host: "localhost", Port: 8080}) {
+		t.Errorf("bad initial configuration: %+v", *cfg)
+	}
+
+	write("port", "9090")
+	sub.f("port", "9090")
+
+	if cfg := store.Load(); *cfg != (storeConfig{Host: "localhost", Port: 9090}) {
+		t.Errorf("bad reloaded configuration: %+v", *cfg)
+	}
+
+	// An invalid configuration must be rejected, keeping the last valid one.
+	write("host", "")
+	sub.f("host", "")
+
+	if cfg := store.Load(); *cfg != (storeConfig{Host: "localhost", Port: 9090}) {
+		t.Errorf("invalid configuration was not rejected: %+v", *cfg)
+	}
+
+	if len(changes) != 1 || changes[0] != (storeConfig{Host: "localhost", Port: 9090}) {
+		t.Errorf("bad change notifications: %+v", changes)
+	}
+
+	if len(errors) != 1 || errors[0].Error() != "invalid value passed to host: zero value" {
+		t.Errorf("bad error notifications: %v", errors)
+	}
+}
+
+func TestNewStoreError(t *testing.T) {
+	if _, err := NewStore[storeConfig](Loader{}, nil); err == nil {
+		t.Error("expected an error when the initial configuration is invalid")
+	}
+}
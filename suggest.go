@@ -0,0 +1,62 @@
+package conf
+
+import "strings"
+
+// suggest returns the candidate closest to name, or an empty string if none of
+// them is close enough to be a likely typo of name. Names are compared without
+// regard to case.
+func suggest(name string, candidates []string) (match string) {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d <= limit && d < len(name) {
+			match, limit = c, d-1
+		}
+	}
+
+	return
+}
+
+// editDistance returns the number of single byte insertions, deletions,
+// substitutions, or transpositions of adjacent bytes needed to turn a into b.
+func editDistance(a, b string) int {
+	// Only the last three rows of the distance matrix are needed to account
+	// for transpositions.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			d := prev[j-1]
+			if a[i-1] != b[j-1] {
+				d++
+			}
+			if x := prev[j] + 1; x < d {
+				d = x
+			}
+			if x := curr[j-1] + 1; x < d {
+				d = x
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				if x := prev2[j-2] + 1; x < d {
+					d = x
+				}
+			}
+			curr[j] = d
+		}
+
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,46 @@
+package conf
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"host", "port", "max_connections", "timeout"}
+
+	tests := []struct {
+		name    string
+		suggest string
+	}{
+		{"max_conections", "max_connections"},
+		{"hots", "host"},
+		{"Port", "port"},
+		{"timeuot", "timeout"},
+		{"user", ""},
+		{"x", ""},
+	}
+
+	for _, test := range tests {
+		if s := suggest(test.name, candidates); s != test.suggest {
+			t.Errorf("%s: bad suggestion: %q", test.name, s)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"host", "hots", 1},
+		{"ca", "abc", 3},
+		{"port", "port", 0},
+	}
+
+	for _, test := range tests {
+		if d := editDistance(test.a, test.b); d != test.d {
+			t.Errorf("%q, %q: bad distance: %d", test.a, test.b, d)
+		}
+	}
+}
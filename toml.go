@@ -0,0 +1,22 @@
+package conf
+
+import (
+	"github.com/BurntSushi/toml"
+	"github.com/segmentio/objconv"
+)
+
+// UnmarshalTOML decodes the TOML document in b into v.
+//
+// The function can be passed to NewFileSource to load configuration files in
+// the TOML format. The document is decoded through objconv, so v may be a Map
+// (or any other objconv.ValueDecoder) and the same field names and tags apply
+// than for other formats.
+func UnmarshalTOML(b []byte, v interface{}) error {
+	var m map[string]interface{}
+
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	return (objconv.Decoder{Parser: objconv.NewValueParser(m)}).Decode(v)
+}
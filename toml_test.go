@@ -0,0 +1,83 @@
+package conf
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalTOML(t *testing.T) {
+	type server struct {
+		Host string `conf:"host"`
+		Port int    `conf:"port"`
+	}
+
+	type config struct {
+		Name    string            `conf:"name"`
+		Debug   bool              `conf:"debug"`
+		Ratio   float64           `conf:"ratio"`
+		Timeout time.Duration     `conf:"timeout"`
+		Start   time.Time         `conf:"start"`
+		Tags    []string          `conf:"tags"`
+		Labels  map[string]string `conf:"labels"`
+		DB      server            `conf:"db"`
+		Servers []server          `conf:"servers"`
+	}
+
+	const configFile = "/tmp/conf-test.toml"
+	os.WriteFile(configFile, []byte(`
+name = "test"
+debug = true
+ratio = 0.5
+timeout = "10s"
+start = 2016-12-06T01:01:42.123456789Z
+tags = ["a", "b"]
+
+[labels]
+team = "core"
+
+[db]
+host = "localhost"
+port = 5432
+
+[[servers]]
+host = "host-1"
+port = 1
+
+[[servers]]
+host = "host-2"
+port = 2
+`), 0644)
+	defer os.Remove(configFile)
+
+	var cfg config
+
+	ld := Loader{
+		Name: "test",
+		Args: []string{"-config-file", configFile},
+		Sources: []Source{
+			NewFileSource("config-file", nil, os.ReadFile, UnmarshalTOML),
+		},
+	}
+
+	if _, _, err := ld.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := config{
+		Name:    "test",
+		Debug:   true,
+		Ratio:   0.5,
+		Timeout: 10 * time.Second,
+		Start:   testTime,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"team": "core"},
+		DB:      server{Host: "localhost", Port: 5432},
+		Servers: []server{{Host: "host-1", Port: 1}, {Host: "host-2", Port: 2}},
+	}
+
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("bad value:\n<<< %#v\n>>> %#v", expected, cfg)
+	}
+}